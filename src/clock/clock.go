@@ -0,0 +1,74 @@
+// Package clock lets the schedulers run against wall time or, in the
+// simulator, against a virtual time that only moves when told to.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	Tick(d time.Duration) <-chan time.Time
+}
+
+// Real is the wall clock.
+type Real struct{}
+
+func (Real) Now() time.Time { return time.Now() }
+
+func (Real) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (Real) Tick(d time.Duration) <-chan time.Time { return time.Tick(d) }
+
+// Virtual is a clock whose time only moves on Advance. Sleepers wake once
+// the time they wait for has been reached.
+type Virtual struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	now  time.Time
+}
+
+func NewVirtual(start time.Time) *Virtual {
+	v := &Virtual{now: start}
+	v.cond = sync.NewCond(&v.mu)
+	return v
+}
+
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+func (v *Virtual) Sleep(d time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	deadline := v.now.Add(d)
+	for v.now.Before(deadline) {
+		v.cond.Wait()
+	}
+}
+
+func (v *Virtual) Tick(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	go func() {
+		for {
+			v.Sleep(d)
+			select {
+			case ch <- v.Now():
+			default:
+			}
+		}
+	}()
+	return ch
+}
+
+// Advance moves the clock forward by d and wakes the sleepers that are due.
+func (v *Virtual) Advance(d time.Duration) {
+	v.mu.Lock()
+	v.now = v.now.Add(d)
+	v.mu.Unlock()
+	v.cond.Broadcast()
+}
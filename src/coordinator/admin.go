@@ -0,0 +1,48 @@
+package main
+
+import (
+	"coordinator/scheduler"
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+)
+
+// serveAdmin serves the read-only JSON admin API:
+//
+//	/api/v1/clusters     registered clusters with share, ledgers and queue depth
+//	/api/v1/idlenodes    nodes the members reported idle room on
+//	/api/v1/shares       dominant share by cluster
+//	/api/v1/ledgers      allocated and contributed resources by cluster
+//	/api/v1/pending      uploaded pods waiting for a placement
+//	/api/v1/placements   recent placement decisions, oldest first
+//
+// It has no authentication, so it listens on localhost unless told
+// otherwise.
+func serveAdmin(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/clusters", adminHandler(func() interface{} { return scheduler.ClusterStatuses() }))
+	mux.Handle("/api/v1/idlenodes", adminHandler(func() interface{} { return scheduler.IdleNodeStatuses() }))
+	mux.Handle("/api/v1/shares", adminHandler(func() interface{} { return scheduler.Shares() }))
+	mux.Handle("/api/v1/ledgers", adminHandler(func() interface{} { return scheduler.Ledgers() }))
+	mux.Handle("/api/v1/pending", adminHandler(func() interface{} { return scheduler.PendingPods() }))
+	mux.Handle("/api/v1/placements", adminHandler(func() interface{} { return scheduler.RecentPlacements() }))
+	glog.Fatal(http.ListenAndServe(addr, mux))
+}
+
+// adminHandler answers GET requests with view encoded as JSON.
+func adminHandler(view func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(view()); err != nil {
+			glog.Info(err)
+		}
+	})
+}
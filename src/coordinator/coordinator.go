@@ -2,31 +2,75 @@ package main
 
 import (
 	"coordinator/scheduler"
+	"crypto/tls"
+	"events"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"net/rpc"
+	"strings"
+	"time"
 	"types"
+	"types/federationpb"
 
 	"github.com/golang/glog"
-)
-
-const (
-	clientPort = "4321"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
 var (
-	pendingPodCh chan types.InterPod
+	pendingPodCh   chan types.InterPod
+	fairnessPolicy = flag.String("fairness_policy", "contribution-drf", "cluster fairness policy: "+strings.Join(scheduler.FairnessPolicies(), ", "))
+	clusterTimeout = flag.Duration("cluster_timeout", 60*time.Second, "mark a cluster unhealthy after this long without heartbeat (0 disables)")
+	rpcTimeout     = flag.Duration("rpc_timeout", 10*time.Second, "deadline of gRPC calls to members")
+	listenAddress  = flag.String("listen_address", ":1234", "address members and fedctl call the coordinator on")
+	tlsCert        = flag.String("tls_cert", "", "certificate of the coordinator, issued to \""+federationpb.CoordinatorIdentity+"\"")
+	tlsKey         = flag.String("tls_key", "", "key of -tls_cert")
+	tlsCA          = flag.String("tls_ca", "", "CA that issues the certificates of the federation")
+	operator       = flag.String("operator_identity", federationpb.OperatorIdentity, "identity of the certificate operators, such as fedctl, call with")
+	metricsAddress = flag.String("metrics_address", ":9234", "address /metrics is served on for Prometheus, empty disables it")
+	adminAddress   = flag.String("admin_address", "localhost:9235", "address the read-only JSON admin API is served on, empty disables it")
+	eventsDir      = flag.String("events_dir", "..", "directory federationData is written to")
+	eventsFormat   = flag.String("events_format", events.FormatCSV, "format of federationData: csv or jsonl")
+	eventsMaxSize  = flag.Int64("events_max_size", 0, "rotate federationData before it grows past this many bytes, 0 never")
+	eventsMaxAge   = flag.Duration("events_max_age", 0, "rotate federationData after this long, 0 never")
+	eventsBackups  = flag.Int("events_max_backups", 0, "rotated federationData files kept, 0 all")
+	eventsSync     = flag.String("events_sync", "none", "fsync of federationData: always, none or an interval such as 5s")
+	stateDir       = flag.String("state_dir", "./state", "directory the coordinator's tables are persisted in, empty keeps them in memory only, which -election does not allow")
+	snapshotEvery  = flag.Duration("snapshot_interval", 5*time.Minute, "how often the tables are snapshotted and the write-ahead log truncated")
+
+	// tlsConfig is nil if TLS is disabled, callers are not authenticated then.
+	tlsConfig *tls.Config
 )
 
 func init() {
 	pendingPodCh = make(chan types.InterPod, 10)
 }
 
-type Server int
+// Server serves the calls of one member, whose certificate is issued to
+// caller.
+type Server struct {
+	caller string
+}
+
+// authorize checks that this replica leads and that the caller is the
+// cluster clusterId a call speaks for.
+func (t *Server) authorize(clusterId string) error {
+	if err := serving(); err != nil {
+		return err
+	}
+	if tlsConfig == nil || t.caller == clusterId {
+		return nil
+	}
+	glog.Warningf("%s is denied to act for cluster %s", t.caller, clusterId)
+	return federationpb.ErrPermissionDenied
+}
 
 func (t *Server) RegisterCluster(cluster *types.Cluster, reply *int) error {
+	if err := t.authorize(cluster.Id); err != nil {
+		return err
+	}
 	glog.Infof("Register cluster:%s, ip:%s, totalResource:%v", cluster.Id, cluster.Ip, cluster.TotalResource)
 	scheduler.RegisterCluster(*cluster)
 	*reply = 1
@@ -34,18 +78,109 @@ func (t *Server) RegisterCluster(cluster *types.Cluster, reply *int) error {
 }
 
 func (t *Server) Heartbeat(cluster *types.Cluster, reply *int) error {
-	scheduler.UpdateCluster(*cluster)
+	if err := t.authorize(cluster.Id); err != nil {
+		return err
+	}
+	if !scheduler.UpdateCluster(*cluster) {
+		return federationpb.NotRegistered(cluster.Id)
+	}
+	*reply = 1
+	return nil
+}
+
+func (t *Server) DeregisterCluster(clusterId *string, reply *int) error {
+	if err := t.authorize(*clusterId); err != nil {
+		return err
+	}
+	if !scheduler.DeregisterCluster(*clusterId) {
+		return federationpb.NotRegistered(*clusterId)
+	}
+	*reply = 1
+	return nil
+}
+
+// ReleasePod is called by the cluster the pod was placed on.
+func (t *Server) ReleasePod(pod *types.InterPod, reply *int) error {
+	dest, ok := scheduler.PlacementDest(*pod)
+	if !ok {
+		dest = pod.ClusterId
+	}
+	if err := t.authorize(dest); err != nil {
+		return err
+	}
+	glog.Infof("ReleasePod:%s of %s", pod.Name, pod.ClusterId)
+	scheduler.ReleasePod(*pod)
+	*reply = 1
+	return nil
+}
+
+// ConfirmPlacement is called by a member before it creates a pod of another
+// cluster, which it does only if the coordinator placed the pod on it.
+func (t *Server) ConfirmPlacement(placement *types.Placement, reply *int) error {
+	if err := t.authorize(placement.DestClusterId); err != nil {
+		return err
+	}
+	if dest, ok := scheduler.PlacementDest(placement.InterPod); !ok || dest != placement.DestClusterId {
+		glog.Warningf("%s of %s is not placed on %s", placement.Name, placement.ClusterId, placement.DestClusterId)
+		return federationpb.ErrPermissionDenied
+	}
 	*reply = 1
 	return nil
 }
 
 func (t *Server) UploadPod(pod *types.InterPod, reply *float64) error {
+	if err := t.authorize(pod.ClusterId); err != nil {
+		return err
+	}
 	pendingPodCh <- *pod
-	*reply = scheduler.Max(float64(pod.RequestMilliCpu)/float64(scheduler.TotalResource.MilliCpu), float64(pod.RequestMemory)/float64(scheduler.TotalResource.Memory))
+	*reply = pod.Request().DominantShare(scheduler.TotalResource)
 	glog.Infof("UploadPod:%v, reply:%f", *pod, *reply)
 	return nil
 }
 
+func (t *Server) UploadPodGroup(group *types.InterPodGroup, reply *types.GroupReservation) error {
+	if err := t.authorize(group.ClusterId); err != nil {
+		return err
+	}
+	reservation, err := scheduler.ReserveGroup(*group)
+	if err != nil {
+		glog.Infof("UploadPodGroup:%s/%s of %s, %v", group.Uid, group.Name, group.ClusterId, err)
+		return err
+	}
+	*reply = reservation
+	glog.Infof("UploadPodGroup:%s/%s of %s, reply:%f", group.Uid, group.Name, group.ClusterId, reply.Weight)
+	return nil
+}
+
+// Reconcile is called by members after registering and then periodically.
+func (t *Server) Reconcile(state *types.MemberState, reply *types.Reconciliation) error {
+	if err := t.authorize(state.ClusterId); err != nil {
+		return err
+	}
+	reconciliation, requeue := scheduler.Reconcile(*state)
+	for _, pod := range requeue {
+		pendingPodCh <- pod
+	}
+	*reply = reconciliation
+	if len(reconciliation.Results)+reconciliation.Requeued+reconciliation.Released+reconciliation.Charged > 0 {
+		glog.Infof("Reconcile %s: %d results, %d requeued, %d released, %d charged", state.ClusterId,
+			len(reconciliation.Results), reconciliation.Requeued, reconciliation.Released, reconciliation.Charged)
+	}
+	return nil
+}
+
+// CordonCluster is called by operators.
+func (t *Server) CordonCluster(cordon *types.Cordon, reply *int) error {
+	if err := t.authorize(*operator); err != nil {
+		return err
+	}
+	if !scheduler.CordonCluster(cordon.ClusterId, cordon.Cordoned) {
+		return fmt.Errorf("cluster %s is not registered", cordon.ClusterId)
+	}
+	*reply = 1
+	return nil
+}
+
 func main() {
 	// setup glog
 	flag.Parse()
@@ -53,16 +188,102 @@ func main() {
 	defer glog.Flush()
 
 	glog.Info("scheduler starts.")
+	if err := scheduler.SetFairnessPolicy(*fairnessPolicy); err != nil {
+		glog.Fatal(err)
+	}
+	glog.Info("fairness policy: ", *fairnessPolicy)
 
-	// create server
-	rpc.Register(new(Server))
-	rpc.HandleHTTP()
-	listener, err := net.Listen("tcp", ":1234")
+	scheduler.SetRpcTimeout(*rpcTimeout)
+	var err error
+	if tlsConfig, err = federationpb.LoadTLS(*tlsCert, *tlsKey, *tlsCA); err != nil {
+		glog.Fatal(err)
+	}
+	if tlsConfig == nil {
+		glog.Warning("TLS is disabled, anyone reaching ", *listenAddress, " can act as any cluster.")
+	}
+	scheduler.SetTLS(tlsConfig)
+	syncInterval, err := events.ParseSync(*eventsSync)
 	if err != nil {
-		fmt.Println(err)
+		glog.Fatal(err)
 	}
-	go http.Serve(listener, nil)
+	eventsConfig := events.Config{
+		Dir:          *eventsDir,
+		Format:       *eventsFormat,
+		MaxSize:      *eventsMaxSize,
+		MaxAge:       *eventsMaxAge,
+		MaxBackups:   *eventsBackups,
+		SyncInterval: syncInterval,
+	}
+	if err := eventsConfig.Validate(); err != nil {
+		glog.Fatal(err)
+	}
+	scheduler.SetEvents(eventsConfig)
+	e, err := newElector()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	identity := *advertiseAddr
+	if e != nil {
+		if *stateDir == "" {
+			glog.Fatal("-election needs -state_dir, replicas follow the leader's write-ahead log")
+		}
+		if identity == "" {
+			if identity, err = defaultAdvertiseAddress(*listenAddress); err != nil {
+				glog.Fatal(err)
+			}
+		}
+	}
+	// recover before the listener accepts calls, members re-sync with
+	// Reconcile.
+	if *stateDir != "" {
+		if err := scheduler.OpenState(*stateDir); err != nil {
+			glog.Fatal(err)
+		}
+	}
+
+	// create server, gRPC and net/rpc share the port.
+	http.Handle(rpc.DefaultRPCPath, federationpb.RPCHandler(func(caller string) *rpc.Server {
+		srv := rpc.NewServer()
+		srv.Register(&Server{caller: caller})
+		return srv
+	}))
+	grpcServer := grpc.NewServer()
+	federationpb.RegisterCoordinatorServer(grpcServer, new(coordinatorServer))
+	listener, err := net.Listen("tcp", *listenAddress)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	go federationpb.Serve(listener, federationpb.Handler(grpcServer, http.DefaultServeMux), tlsConfig)
+	if *metricsAddress != "" {
+		go serveMetrics(*metricsAddress)
+	}
+	if *adminAddress != "" {
+		go serveAdmin(*adminAddress)
+	}
+	go scheduler.SnapshotState(*snapshotEvery)
+	if e == nil {
+		leading = true
+		leaderGauge.Set(1)
+		scheduler.Lead()
+		lead()
+		return
+	}
+	glog.Infof("Campaign for the leadership of the coordinator replicas as %s.", identity)
+	campaign(e, identity, lead)
+}
+
+// lead schedules, which only the leader does.
+func lead() {
 	go scheduler.DispatchPods(pendingPodCh)
 	go scheduler.HandleClusterData()
+	go scheduler.WatchClusters(*clusterTimeout)
 	scheduler.Schedule()
 }
+
+// serveMetrics serves Prometheus on its own plain HTTP listener, apart from
+// the mutual TLS of the federation port.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	glog.Fatal(http.ListenAndServe(addr, mux))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"coordinator/scheduler"
+	"types"
+	"types/federationpb"
+
+	"github.com/golang/glog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// coordinatorServer serves the gRPC protocol with the handlers of the
+// net/rpc Server, which stays registered for members that predate it.
+type coordinatorServer struct {
+	federationpb.UnimplementedCoordinatorServer
+}
+
+// server is the net/rpc Server of the caller of ctx.
+func server(ctx context.Context) *Server {
+	return &Server{caller: federationpb.CallerIdentity(ctx)}
+}
+
+// statusError turns a handler error into a gRPC status, code is used for
+// errors other than a denied permission or a replica that does not lead.
+func statusError(err error, code codes.Code) error {
+	if err == federationpb.ErrPermissionDenied {
+		code = codes.PermissionDenied
+	}
+	if _, ok := federationpb.LeaderOf(err); ok {
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
+
+func (s *coordinatorServer) RegisterCluster(ctx context.Context, req *federationpb.RegisterClusterRequest) (*federationpb.RegisterClusterResponse, error) {
+	version, ok := federationpb.Negotiate(req.MinVersion, req.MaxVersion)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "protocol versions %d-%d are not supported, want %d-%d",
+			req.MinVersion, req.MaxVersion, federationpb.MinProtocolVersion, federationpb.ProtocolVersion)
+	}
+	cluster := federationpb.DecodeCluster(req.Cluster)
+	if err := server(ctx).authorize(cluster.Id); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	cluster.Version = version
+	glog.Infof("Register cluster:%s, ip:%s, protocol:%d, totalResource:%v", cluster.Id, cluster.Ip, version, cluster.TotalResource)
+	scheduler.RegisterCluster(cluster)
+	return &federationpb.RegisterClusterResponse{Version: version}, nil
+}
+
+func (s *coordinatorServer) Heartbeat(ctx context.Context, req *federationpb.HeartbeatRequest) (*federationpb.Empty, error) {
+	cluster := federationpb.DecodeCluster(req.Cluster)
+	var reply int
+	if err := server(ctx).Heartbeat(&cluster, &reply); err != nil {
+		return nil, statusError(err, codes.NotFound)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *coordinatorServer) DeregisterCluster(ctx context.Context, req *federationpb.DeregisterClusterRequest) (*federationpb.Empty, error) {
+	var reply int
+	if err := server(ctx).DeregisterCluster(&req.ClusterId, &reply); err != nil {
+		return nil, statusError(err, codes.NotFound)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *coordinatorServer) UploadPod(ctx context.Context, req *federationpb.UploadPodRequest) (*federationpb.UploadPodResponse, error) {
+	pod := types.InterPod{Pod: federationpb.DecodePod(req.Pod), ClusterId: req.ClusterId}
+	var weight float64
+	if err := server(ctx).UploadPod(&pod, &weight); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return &federationpb.UploadPodResponse{Weight: weight}, nil
+}
+
+func (s *coordinatorServer) UploadPodGroup(ctx context.Context, req *federationpb.UploadPodGroupRequest) (*federationpb.GroupReservation, error) {
+	group := types.InterPodGroup{PodGroup: federationpb.DecodePodGroup(req.Group), ClusterId: req.ClusterId}
+	var reservation types.GroupReservation
+	if err := server(ctx).UploadPodGroup(&group, &reservation); err != nil {
+		return nil, statusError(err, codes.ResourceExhausted)
+	}
+	return federationpb.EncodeGroupReservation(reservation), nil
+}
+
+func (s *coordinatorServer) CordonCluster(ctx context.Context, req *federationpb.CordonClusterRequest) (*federationpb.Empty, error) {
+	cordon := types.Cordon{ClusterId: req.ClusterId, Cordoned: req.Cordoned}
+	var reply int
+	if err := server(ctx).CordonCluster(&cordon, &reply); err != nil {
+		return nil, statusError(err, codes.NotFound)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *coordinatorServer) ReleasePod(ctx context.Context, req *federationpb.ReleasePodRequest) (*federationpb.Empty, error) {
+	pod := types.InterPod{Pod: federationpb.DecodePod(req.Pod), ClusterId: req.ClusterId}
+	var reply int
+	if err := server(ctx).ReleasePod(&pod, &reply); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *coordinatorServer) ConfirmPlacement(ctx context.Context, req *federationpb.ConfirmPlacementRequest) (*federationpb.Empty, error) {
+	placement := types.Placement{
+		InterPod:      types.InterPod{Pod: federationpb.DecodePod(req.Pod), ClusterId: req.ClusterId},
+		DestClusterId: req.DestClusterId,
+	}
+	var reply int
+	if err := server(ctx).ConfirmPlacement(&placement, &reply); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *coordinatorServer) Reconcile(ctx context.Context, req *federationpb.MemberState) (*federationpb.Reconciliation, error) {
+	state := federationpb.DecodeMemberState(req)
+	var reconciliation types.Reconciliation
+	if err := server(ctx).Reconcile(&state, &reconciliation); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return federationpb.EncodeReconciliation(reconciliation), nil
+}
+
+// Replicate is called by the other coordinator replicas, which may call
+// any replica: one that does not lead has nothing new to send.
+func (s *coordinatorServer) Replicate(ctx context.Context, req *federationpb.ReplicateRequest) (*federationpb.ReplicateResponse, error) {
+	if caller := federationpb.CallerIdentity(ctx); tlsConfig != nil && caller != federationpb.CoordinatorIdentity {
+		glog.Warningf("%s is denied to replicate the coordinator", caller)
+		return nil, status.Error(codes.PermissionDenied, federationpb.ErrPermissionDenied.Error())
+	}
+	if err := serving(); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	replication, err := scheduler.Replicate(req.Seq, req.Snapshot, replicateWait)
+	if err != nil {
+		return nil, statusError(err, codes.FailedPrecondition)
+	}
+	return &federationpb.ReplicateResponse{Snapshot: replication.Snapshot, Entries: replication.Entries}, nil
+}
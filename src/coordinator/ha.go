@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"coordinator/scheduler"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"types/federationpb"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Coordinator replicas elect a leader, identified by the address it is
+// reached at. Only the leader serves members and schedules, the others
+// replicate its tables and answer members with its address. A leader that
+// loses the election exits, to come back as a replica of the next one.
+
+// replicateWait is how long the leader holds a Replicate call for a new
+// entry.
+const replicateWait = 10 * time.Second
+
+var (
+	election       = flag.String("election", "none", "leader election among coordinator replicas: none for a single coordinator, lease for a Kubernetes Lease, file for a lock file the replicas share")
+	advertiseAddr  = flag.String("advertise_address", "", "address members and other replicas reach this coordinator at, default <hostname>:<port of -listen_address>")
+	leaseName      = flag.String("lease_name", "federation-coordinator", "Lease the replicas elect the leader with")
+	leaseNamespace = flag.String("lease_namespace", "default", "namespace of -lease_name")
+	leaseFile      = flag.String("lease_file", "./coordinator.lease", "lock file the replicas elect the leader with under -election=file")
+	kubeconfig     = flag.String("kubeconfig", "", "kubeconfig of the cluster holding the Lease, empty in-cluster")
+	leaseDuration  = flag.Duration("lease_duration", 15*time.Second, "how long the lease outlives the last renewal of its holder")
+	renewDeadline  = flag.Duration("renew_deadline", 10*time.Second, "how long the leader tries to renew the lease before it steps down")
+	retryPeriod    = flag.Duration("retry_period", 2*time.Second, "how often the lease is tried for or renewed")
+
+	// leaderLock guards the fields below.
+	leaderLock sync.Mutex
+	// leader is the address of the leader, empty while unknown.
+	leader  string
+	leading bool
+	// following replicates the tables of leader while this replica does
+	// not lead, nil otherwise.
+	following *follower
+
+	leaderGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "federation_coordinator_leader",
+		Help: "1 if this coordinator replica leads, 0 if it replicates the leader.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(leaderGauge)
+}
+
+// elector campaigns for the leadership of the replicas as identity. Run
+// calls observe whenever the leader changes and lead once identity is
+// elected, neither may block. It returns once identity no longer leads.
+type elector interface {
+	Run(identity string, lead func(), observe func(leader string))
+}
+
+// newElector returns the elector of -election, nil for none.
+func newElector() (elector, error) {
+	switch *election {
+	case "none":
+		return nil, nil
+	case "file":
+		return fileElector{*leaseFile}, nil
+	case "lease":
+		config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		client, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return leaseElector{client}, nil
+	}
+	return nil, fmt.Errorf("invalid election %q, want none, lease or file", *election)
+}
+
+// serving checks that this replica leads, the only one members may call.
+func serving() error {
+	leaderLock.Lock()
+	defer leaderLock.Unlock()
+	if leading {
+		return nil
+	}
+	return federationpb.NotLeader(leader)
+}
+
+// campaign runs e until this replica, once elected, loses the election.
+// run starts the scheduling of the leader.
+func campaign(e elector, identity string, run func()) {
+	e.Run(identity, func() {
+		leaderLock.Lock()
+		following.stop()
+		following = nil
+		leader, leading = identity, true
+		leaderLock.Unlock()
+		leaderGauge.Set(1)
+		glog.Infof("Elected leader of the coordinator replicas as %s.", identity)
+		scheduler.Lead()
+		go run()
+	}, func(addr string) {
+		leaderLock.Lock()
+		defer leaderLock.Unlock()
+		if addr == leader || leading {
+			return
+		}
+		glog.Infof("Leader of the coordinator replicas: %s", addr)
+		leader = addr
+		following.stop()
+		following = nil
+		if addr != "" && addr != identity {
+			following = follow(addr)
+		}
+	})
+	glog.Fatal("Lost the leadership of the coordinator replicas, exit to rejoin as a replica.")
+}
+
+// follower replicates the tables of a leader.
+type follower struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// follow starts replicating the tables of the leader at addr, from a
+// snapshot.
+func follow(addr string) *follower {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &follower{cancel, make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		conn, err := federationpb.Dial(addr, federationpb.WithServerName(tlsConfig, federationpb.CoordinatorIdentity))
+		if err != nil {
+			glog.Errorf("replicate from %s: %v", addr, err)
+			return
+		}
+		defer conn.Close()
+		client := federationpb.NewCoordinatorClient(conn)
+		full := true
+		for ctx.Err() == nil {
+			callCtx, callCancel := context.WithTimeout(ctx, replicateWait+*rpcTimeout)
+			resp, err := client.Replicate(callCtx, &federationpb.ReplicateRequest{Seq: scheduler.ReplicatedSeq(), Snapshot: full})
+			callCancel()
+			if err == nil {
+				if err = scheduler.Follow(scheduler.Replication{Snapshot: resp.Snapshot, Entries: resp.Entries}); err == nil {
+					full = false
+					continue
+				}
+				full = true
+			}
+			if ctx.Err() == nil {
+				glog.Warningf("replicate from %s: %v", addr, err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(*retryPeriod):
+			}
+		}
+	}()
+	return f
+}
+
+// stop stops f and waits until it no longer changes the tables.
+func (f *follower) stop() {
+	if f == nil {
+		return
+	}
+	f.cancel()
+	<-f.done
+}
+
+// defaultAdvertiseAddress is the hostname with the port of listenAddr.
+func defaultAdvertiseAddress(listenAddr string) (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	_, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// leaseElector elects with a Kubernetes Lease, its service account needs
+// to get, create and update leases in -lease_namespace.
+type leaseElector struct {
+	client kubernetes.Interface
+}
+
+func (e leaseElector) Run(identity string, lead func(), observe func(string)) {
+	leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: *leaseName, Namespace: *leaseNamespace},
+			Client:     e.client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration: *leaseDuration,
+		RenewDeadline: *renewDeadline,
+		RetryPeriod:   *retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) { lead() },
+			OnStoppedLeading: func() {},
+			OnNewLeader:      observe,
+		},
+	})
+}
+
+// fileElector elects with a lease kept in a file the replicas share, for
+// tests and replicas on one host. flock only guards the updates of the
+// lease, which expires like a Lease does.
+type fileElector struct {
+	path string
+}
+
+type fileLease struct {
+	Holder    string    `json:"holder"`
+	RenewTime time.Time `json:"renewTime"`
+}
+
+func (e fileElector) Run(identity string, lead func(), observe func(string)) {
+	elected := false
+	var renewed time.Time
+	for ; ; time.Sleep(*retryPeriod) {
+		holder, err := e.acquire(identity)
+		if err != nil {
+			glog.Warningf("lease %s: %v", e.path, err)
+		}
+		switch {
+		case err == nil && holder == identity:
+			renewed = time.Now()
+			if !elected {
+				elected = true
+				observe(identity)
+				lead()
+			}
+		case elected && (err == nil || time.Since(renewed) > *renewDeadline):
+			return
+		case err == nil:
+			observe(holder)
+		}
+	}
+}
+
+// acquire takes or renews the lease for identity if it is free, expired or
+// already held by identity, and returns its holder.
+func (e fileElector) acquire(identity string) (string, error) {
+	file, err := os.OpenFile(e.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	var lease fileLease
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &lease); err != nil {
+			return "", err
+		}
+	}
+	now := time.Now()
+	if lease.Holder != "" && lease.Holder != identity && now.Before(lease.RenewTime.Add(*leaseDuration)) {
+		return lease.Holder, nil
+	}
+	data, err = json.Marshal(fileLease{identity, now})
+	if err != nil {
+		return "", err
+	}
+	if err := file.Truncate(0); err != nil {
+		return "", err
+	}
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return "", err
+	}
+	return identity, file.Sync()
+}
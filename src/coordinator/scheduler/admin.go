@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"sort"
+	"types"
+)
+
+// The functions below are read-only views of the scheduler for the admin
+// API. Each returns a copy taken under mu, sorted by cluster id.
+
+func ClusterStatuses() []types.ClusterStatus {
+	queued := make(map[string]int)
+	for _, pod := range PendingPods() {
+		queued[pod.ClusterId]++
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	statuses := make([]types.ClusterStatus, 0, len(clustersInfo))
+	for id, cluster := range clustersInfo {
+		statuses = append(statuses, types.ClusterStatus{
+			Id:            id,
+			Ip:            cluster.Ip,
+			Port:          clusterPort(cluster),
+			Version:       cluster.Version,
+			Weight:        cluster.Weight,
+			Healthy:       cluster.Healthy,
+			Cordoned:      cordoned[id],
+			LastHeartbeat: cluster.LastHeartbeat,
+			TotalResource: cluster.TotalResource.Dims(),
+			Nodes:         len(cluster.Nodes),
+			Share:         clustersShare[id],
+			Allocated:     allocatedResource[id].Dims(),
+			Contributed:   contributedResource[id].Dims(),
+			PendingPods:   queued[id],
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Id < statuses[j].Id })
+	return statuses
+}
+
+func IdleNodeStatuses() []types.IdleNodeStatus {
+	mu.Lock()
+	defer mu.Unlock()
+	nodes := make([]types.IdleNodeStatus, 0, len(IdleNodes))
+	for _, node := range IdleNodes {
+		nodes = append(nodes, types.IdleNodeStatus{
+			ClusterId: node.ClusterId,
+			Node:      node.Name,
+			Idle:      node.IdleResource.Dims(),
+			Capacity:  node.Resource.Dims(),
+			Labels:    node.Labels,
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].ClusterId != nodes[j].ClusterId {
+			return nodes[i].ClusterId < nodes[j].ClusterId
+		}
+		return nodes[i].Node < nodes[j].Node
+	})
+	return nodes
+}
+
+func Shares() map[string]float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	shares := make(map[string]float64, len(clustersShare))
+	for id, share := range clustersShare {
+		shares[id] = share
+	}
+	return shares
+}
+
+func Ledgers() map[string]types.Ledger {
+	mu.Lock()
+	defer mu.Unlock()
+	ledgers := make(map[string]types.Ledger, len(clustersInfo))
+	for id := range clustersInfo {
+		ledgers[id] = types.Ledger{
+			Allocated:   allocatedResource[id].Dims(),
+			Contributed: contributedResource[id].Dims(),
+		}
+	}
+	return ledgers
+}
+
+// PendingPods returns the uploaded pods not placed yet, in queue order
+// within each cluster.
+func PendingPods() []types.PendingPod {
+	pendingLock.Lock()
+	defer pendingLock.Unlock()
+	pods := make([]types.PendingPod, 0, len(pending))
+	for _, pod := range pending {
+		pods = append(pods, types.PendingPod{
+			ClusterId: pod.ClusterId,
+			Tenant:    pod.Uid,
+			Name:      pod.Name,
+			Request:   pod.Request().Dims(),
+			QueuedAt:  pod.queuedAt.Unix(),
+		})
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		if pods[i].ClusterId != pods[j].ClusterId {
+			return pods[i].ClusterId < pods[j].ClusterId
+		}
+		if pods[i].QueuedAt != pods[j].QueuedAt {
+			return pods[i].QueuedAt < pods[j].QueuedAt
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return pods
+}
+
+// RecentPlacements returns the last placement decisions, oldest first.
+func RecentPlacements() []types.PlacementRecord {
+	mu.Lock()
+	defer mu.Unlock()
+	return append(make([]types.PlacementRecord, 0, len(recentPlacements)), recentPlacements...)
+}
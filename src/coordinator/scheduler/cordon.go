@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"types"
+
+	"github.com/golang/glog"
+)
+
+// cordoned clusters get no pods of other clusters. It is guarded by mu and
+// kept across re-registration, so an operator's cordon survives a restart of
+// the member.
+var cordoned map[string]bool
+
+func init() {
+	cordoned = make(map[string]bool)
+}
+
+// CordonCluster cordons or uncordons a cluster. It returns false if the
+// cluster is not registered.
+func CordonCluster(clusterId string, cordon bool) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := clustersInfo[clusterId]; !ok {
+		return false
+	}
+	commit(walEntry{Op: opCordon, ClusterId: clusterId, Cordoned: cordon})
+	return true
+}
+
+func setCordoned(clusterId string, cordon bool) {
+	if cordon {
+		cordoned[clusterId] = true
+		glog.Infof("Cordon cluster %s.", clusterId)
+	} else {
+		delete(cordoned, clusterId)
+		glog.Infof("Uncordon cluster %s.", clusterId)
+	}
+}
+
+// placeable reports whether pod may be placed on clusterId, which a cordon
+// only forbids for pods of other clusters.
+func placeable(clusterId string, pod types.InterPod) bool {
+	return !cordoned[clusterId] || clusterId == pod.ClusterId
+}
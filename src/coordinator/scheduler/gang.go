@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"types"
+
+	"github.com/golang/glog"
+)
+
+// ReserveGroup places every pod of a group on the idle nodes of one or more
+// clusters, or none of them. Unlike single pods, groups are answered right
+// away instead of waiting for their cluster's turn in Schedule, so that the
+// member can create all pods only once all of them have a destination.
+func ReserveGroup(group types.InterPodGroup) (types.GroupReservation, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	source, ok := clustersInfo[group.ClusterId]
+	if !ok {
+		return types.GroupReservation{}, fmt.Errorf("cluster %s is not registered", group.ClusterId)
+	}
+
+	// reserve on a copy of IdleNodes, biggest pods first.
+	idleNodes := make(map[string]types.InterNode, len(IdleNodes))
+	nodeNames := make([]string, 0, len(IdleNodes))
+	for name, node := range IdleNodes {
+		idleNodes[name] = node
+		nodeNames = append(nodeNames, name)
+	}
+	sort.Strings(nodeNames)
+	pods := make([]types.InterPod, 0, len(group.Pods))
+	for _, pod := range group.Pods {
+		pods = append(pods, types.InterPod{Pod: pod, ClusterId: group.ClusterId})
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].Request().DominantShare(TotalResource) > pods[j].Request().DominantShare(TotalResource)
+	})
+	dest := make([]string, len(pods))
+	destNodes := make([]string, len(pods))
+	for i, pod := range pods {
+		for _, name := range nodeNames {
+			node := idleNodes[name]
+			if !placeable(node.ClusterId, pod) {
+				continue
+			}
+			if pod.Request().Fits(node.IdleResource) && pod.Constraints.Fits(node.Node) {
+				node.IdleResource = node.IdleResource.Sub(pod.Request())
+				idleNodes[name] = node
+				dest[i] = node.ClusterId
+				destNodes[i] = name
+				break
+			}
+		}
+		if dest[i] == "" {
+			return types.GroupReservation{}, fmt.Errorf("no room for %s of group %s/%s", pod.Name, group.Uid, group.Name)
+		}
+	}
+
+	// commit
+	var reservation types.GroupReservation
+	var request types.Resource
+	for i, pod := range pods {
+		request = request.Add(pod.Request())
+		commit(walEntry{Op: opPlace, Pod: &pod, Dest: dest[i], Node: destNodes[i]})
+		reservation.Results = append(reservation.Results, resultFor(pod.Pod, clustersInfo[dest[i]]))
+		recordPlaced(pod, dest[i], idleNodes[destNodes[i]].Name, "reserved with group "+group.Uid+"/"+group.Name)
+		glog.Infof("Reserve %s of group %s/%s of %s on %s.", pod.Name, group.Uid, group.Name, source.Id, dest[i])
+	}
+	reservation.Weight = request.DominantShare(TotalResource)
+	return reservation, nil
+}
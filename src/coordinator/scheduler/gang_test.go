@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"testing"
+	"types"
+)
+
+func TestReserveGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		milliCpu []int64
+		want     []string // destination of each pod, biggest first; nil if the group is refused
+	}{
+		{"fits on the source", "cluster1", []int64{1000, 1000}, []string{"cluster1", "cluster1"}},
+		{"spans clusters", "cluster1", []int64{2000, 3000}, []string{"cluster2", "cluster1"}},
+		{"one pod does not fit", "cluster1", []int64{2000, 5000}, nil},
+		{"unregistered source", "cluster3", []int64{1000}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearTables(t)
+			registerTestCluster("cluster1", 2000, 4096)
+			registerTestCluster("cluster2", 4000, 4096)
+			group := types.InterPodGroup{PodGroup: types.PodGroup{Name: "g", Uid: "tenant1", MinMember: len(test.milliCpu)}, ClusterId: test.source}
+			for i, milliCpu := range test.milliCpu {
+				pod := testInterPod(test.source, string(rune('a'+i)), milliCpu, 1024)
+				group.Pods = append(group.Pods, pod.Pod)
+			}
+			idle := make(map[string]types.InterNode)
+			for name, node := range IdleNodes {
+				idle[name] = node
+			}
+
+			reservation, err := ReserveGroup(group)
+			if test.want == nil {
+				if err == nil {
+					t.Fatal("group reserved")
+				}
+				if len(placements) != 0 {
+					t.Errorf("%d placements charged for a refused group", len(placements))
+				}
+				for name, node := range idle {
+					if !IdleNodes[name].IdleResource.Equal(node.IdleResource) {
+						t.Errorf("%s left %v idle, want %v", name, IdleNodes[name].IdleResource, node.IdleResource)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			charged := 0
+			for _, result := range reservation.Results {
+				got = append(got, result.DestClusterId)
+				if result.DestClusterId != test.source {
+					charged++
+				}
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("destinations %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("destinations %v, want %v", got, test.want)
+					break
+				}
+			}
+			if len(placements) != charged {
+				t.Errorf("%d placements charged, want %d", len(placements), charged)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"time"
+	"types"
+
+	"github.com/golang/glog"
+)
+
+// WatchClusters marks clusters that have not sent a heartbeat within timeout
+// as unhealthy, so their idle capacity is no longer offered to other clusters.
+func WatchClusters(timeout time.Duration) {
+	if timeout <= 0 {
+		glog.Info("cluster liveness tracking is disabled.")
+		return
+	}
+	for range clk.Tick(timeout / 2) {
+		expireClusters(clk.Now().Add(-timeout).Unix())
+	}
+}
+
+func expireClusters(deadline int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	for id, cluster := range clustersInfo {
+		if cluster.Healthy && cluster.LastHeartbeat < deadline {
+			glog.Warningf("Cluster %s missed heartbeats since %d, mark it unhealthy.", id, cluster.LastHeartbeat)
+			commit(walEntry{Op: opExpire, ClusterId: id})
+		}
+	}
+}
+
+// DeregisterCluster removes a cluster from the federation, e.g. on a planned
+// shutdown. Pods still queued for it are dropped.
+func DeregisterCluster(id string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := clustersInfo[id]; !ok {
+		return false
+	}
+	commit(walEntry{Op: opDeregister, ClusterId: id})
+	glog.Infof("Deregister cluster:%s, TotalResource:%v", id, TotalResource)
+	return true
+}
+
+func markUnhealthy(cluster types.Cluster) {
+	removeIdleNodes(cluster.Id)
+	TotalResource = TotalResource.Sub(cluster.TotalResource)
+	cluster.Healthy = false
+	clustersInfo[cluster.Id] = cluster
+	glog.Info("TotalResource:", TotalResource)
+}
+
+func removeCluster(cluster types.Cluster) {
+	if cluster.Healthy {
+		markUnhealthy(cluster)
+	}
+	delete(clustersInfo, cluster.Id)
+	delete(clustersShare, cluster.Id)
+	delete(allocatedResource, cluster.Id)
+	delete(contributedResource, cluster.Id)
+	delete(cordoned, cluster.Id)
+	forgetCluster(cluster.Id)
+	if podsQ, ok := clustersPodsQ[cluster.Id]; ok {
+		for len(podsQ) > 0 {
+			pod := <-podsQ
+			forgetQueued(pod)
+			glog.Warningf("Drop pending pod %s of deregistered cluster %s.", pod.Name, cluster.Id)
+		}
+	}
+}
+
+func removeIdleNodes(clusterId string) {
+	for nodeName, node := range IdleNodes {
+		if node.ClusterId == clusterId {
+			delete(IdleNodes, nodeName)
+		}
+	}
+}
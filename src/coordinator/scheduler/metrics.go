@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+	"types"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are exported to Prometheus by the coordinator's /metrics. Gauges
+// are set where the ledgers change, so scrapes never touch the tables.
+var (
+	clusterShareGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_cluster_dominant_share",
+		Help: "Dominant share of a cluster under the fairness policy.",
+	}, []string{"cluster"})
+	clusterAllocatedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_cluster_allocated_resource",
+		Help: "Resources a cluster's pods hold on other clusters.",
+	}, []string{"cluster", "resource"})
+	clusterContributedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_cluster_contributed_resource",
+		Help: "Resources a cluster gives to pods of other clusters.",
+	}, []string{"cluster", "resource"})
+	clusterQueueGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_cluster_queue_depth",
+		Help: "Pods of a cluster waiting in clustersPodsQ.",
+	}, []string{"cluster"})
+	placedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "federation_pods_placed_total",
+		Help: "Pods placed by the coordinator, by source and destination cluster.",
+	}, []string{"source", "dest"})
+	scheduleLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "federation_coordinator_schedule_latency_seconds",
+		Help:    "Time from upload to placement of a pod.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	})
+	rpcErrorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "federation_rpc_errors_total",
+		Help: "Failed calls to members, by method.",
+	}, []string{"method"})
+
+	// pending mirrors clustersPodsQ, which cannot be read without taking
+	// the pods, keyed by podKey.
+	pending     map[string]pendingPod
+	pendingLock sync.Mutex
+	// recentPlacements are the last maxRecentPlacements decisions, oldest
+	// first. It is guarded by mu.
+	recentPlacements []types.PlacementRecord
+)
+
+const maxRecentPlacements = 100
+
+// pendingPod is a pod in clustersPodsQ and when it was queued.
+type pendingPod struct {
+	types.InterPod
+	queuedAt time.Time
+}
+
+func init() {
+	pending = make(map[string]pendingPod)
+	prometheus.MustRegister(clusterShareGauge, clusterAllocatedGauge, clusterContributedGauge,
+		clusterQueueGauge, placedCounter, scheduleLatency, rpcErrorCounter)
+}
+
+// recordCluster exports the share and ledgers of a cluster.
+func recordCluster(clusterId string) {
+	clusterShareGauge.WithLabelValues(clusterId).Set(clustersShare[clusterId])
+	setResourceGauge(clusterAllocatedGauge, clusterId, allocatedResource[clusterId])
+	setResourceGauge(clusterContributedGauge, clusterId, contributedResource[clusterId])
+}
+
+func setResourceGauge(gauge *prometheus.GaugeVec, clusterId string, res types.Resource) {
+	gauge.DeletePartialMatch(prometheus.Labels{"cluster": clusterId})
+	for name, v := range res.Dims() {
+		gauge.WithLabelValues(clusterId, name).Set(float64(v))
+	}
+}
+
+// forgetCluster drops the series of a removed cluster.
+func forgetCluster(clusterId string) {
+	labels := prometheus.Labels{"cluster": clusterId}
+	clusterShareGauge.DeletePartialMatch(labels)
+	clusterAllocatedGauge.DeletePartialMatch(labels)
+	clusterContributedGauge.DeletePartialMatch(labels)
+	clusterQueueGauge.DeletePartialMatch(labels)
+}
+
+func recordQueue(clusterId string, podsQ chan types.InterPod) {
+	clusterQueueGauge.WithLabelValues(clusterId).Set(float64(len(podsQ)))
+}
+
+func recordQueued(pod types.InterPod) {
+	pendingLock.Lock()
+	pending[podKey(pod)] = pendingPod{pod, clk.Now()}
+	pendingLock.Unlock()
+}
+
+// forgetQueued drops a queued pod that will never be placed.
+func forgetQueued(pod types.InterPod) {
+	pendingLock.Lock()
+	delete(pending, podKey(pod))
+	pendingLock.Unlock()
+}
+
+// recordPlaced counts a placement and, for uploaded pods, how long they
+// waited for it. node is the idle node pod fit on, if any, and reason why
+// destClusterId was chosen. It is called with mu held.
+func recordPlaced(pod types.InterPod, destClusterId, node, reason string) {
+	placedCounter.WithLabelValues(pod.ClusterId, destClusterId).Inc()
+	pendingLock.Lock()
+	queued, ok := pending[podKey(pod)]
+	delete(pending, podKey(pod))
+	pendingLock.Unlock()
+	if ok {
+		scheduleLatency.Observe(clk.Now().Sub(queued.queuedAt).Seconds())
+	}
+	if len(recentPlacements) == maxRecentPlacements {
+		recentPlacements = append(recentPlacements[:0], recentPlacements[1:]...)
+	}
+	recentPlacements = append(recentPlacements, types.PlacementRecord{
+		Time:    clk.Now().Unix(),
+		Source:  pod.ClusterId,
+		Dest:    destClusterId,
+		Node:    node,
+		Tenant:  pod.Uid,
+		Name:    pod.Name,
+		Group:   pod.Group,
+		Request: pod.Request().Dims(),
+		Reason:  reason,
+	})
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"types"
+)
+
+// FairnessPolicy decides how clusters are ordered in the federation.
+// Policies read the shared allocatedResource and contributedResource ledgers,
+// which are maintained by the scheduler itself.
+type FairnessPolicy interface {
+	// Share returns the priority of a cluster, the cluster with the lowest
+	// share is served first.
+	Share(clusterId string) float64
+	// Allocated is called after pod has been placed on destClusterId and the
+	// ledgers have been charged, Released after a placement has been given
+	// back to them. Policies keeping state of their own update it here.
+	Allocated(pod types.InterPod, destClusterId string)
+	Released(placement types.Placement)
+	// Fallback chooses the destination of pod when no idle node fits it.
+	Fallback(pod types.InterPod) string
+}
+
+var (
+	policy   FairnessPolicy = contributionDRF{}
+	policies                = map[string]FairnessPolicy{
+		"contribution-drf": contributionDRF{},
+		"drf":              plainDRF{},
+		"weighted-drf":     weightedDRF{},
+		"proportional":     proportionalShare{},
+	}
+)
+
+// SetFairnessPolicy selects one of the built-in policies by name.
+func SetFairnessPolicy(name string) error {
+	p, ok := policies[name]
+	if !ok {
+		return fmt.Errorf("unknown fairness policy %q, want one of %v", name, FairnessPolicies())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	policy = p
+	for id := range clustersShare {
+		computeClusterShare(id)
+	}
+	return nil
+}
+
+// FairnessPolicies lists the names of the built-in policies.
+func FairnessPolicies() []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// baseFairness implements the hooks and the fallback shared by the built-in
+// policies, which only differ in how a share is computed.
+type baseFairness struct{}
+
+// Allocated recomputes the share of the destination, which lends to pod.
+// The source's share was recomputed as it was charged.
+func (baseFairness) Allocated(pod types.InterPod, destClusterId string) {
+	computeClusterShare(destClusterId)
+}
+
+// Released recomputes the shares of the clusters of placement that are
+// still registered.
+func (baseFairness) Released(placement types.Placement) {
+	for _, id := range []string{placement.ClusterId, placement.DestClusterId} {
+		if _, ok := clustersShare[id]; ok {
+			computeClusterShare(id)
+		}
+	}
+}
+
+// Fallback picks the healthy, uncordoned cluster with the lowest share among
+// those with a node the pod may run on, staying on the source cluster on
+// ties.
+func (baseFairness) Fallback(pod types.InterPod) string {
+	destClusterId := pod.ClusterId
+	minShare := clustersShare[pod.ClusterId]
+	ids := make([]string, 0, len(clustersShare))
+	for c := range clustersShare {
+		ids = append(ids, c)
+	}
+	sort.Strings(ids)
+	for _, c := range ids {
+		s := clustersShare[c]
+		if !clustersInfo[c].Healthy || !placeable(c, pod) || !canSatisfy(clustersInfo[c], pod) {
+			continue
+		}
+		if s < minShare {
+			minShare = s
+			destClusterId = c
+		}
+	}
+	return destClusterId
+}
+
+// contributionDRF divides the dominant share by one plus the dominant
+// contribution, so clusters that lend resources get served earlier.
+type contributionDRF struct{ baseFairness }
+
+func (contributionDRF) Share(clusterId string) float64 {
+	dominantContribution := dominantShare(contributedResource[clusterId])
+	return dominantShare(allocatedResource[clusterId]) / (1 + dominantContribution)
+}
+
+// plainDRF is dominant resource fairness without regard to contribution.
+type plainDRF struct{ baseFairness }
+
+func (plainDRF) Share(clusterId string) float64 {
+	return dominantShare(allocatedResource[clusterId])
+}
+
+// weightedDRF divides the dominant share by the cluster's registered
+// Weight, treating a missing weight as 1.
+type weightedDRF struct{ baseFairness }
+
+func (weightedDRF) Share(clusterId string) float64 {
+	weight := clustersInfo[clusterId].Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	return dominantShare(allocatedResource[clusterId]) / weight
+}
+
+// proportionalShare entitles each cluster to the fraction of the federation
+// it provides, its own capacity plus what it has lent out, and measures
+// usage against that entitlement.
+type proportionalShare struct{ baseFairness }
+
+func (proportionalShare) Share(clusterId string) float64 {
+	provided := clustersInfo[clusterId].TotalResource.Add(contributedResource[clusterId])
+	entitlement := dominantShare(provided)
+	if entitlement <= 0 {
+		return dominantShare(allocatedResource[clusterId])
+	}
+	return dominantShare(allocatedResource[clusterId]) / entitlement
+}
+
+func dominantShare(res types.Resource) float64 {
+	return res.DominantShare(TotalResource)
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"types"
+)
+
+func TestPolicyShare(t *testing.T) {
+	tests := []struct {
+		policy  string
+		cluster string
+		want    float64
+	}{
+		// cluster1 is allocated 4 of 20 cpus and lends 5.
+		{"drf", "cluster1", 0.2},
+		{"contribution-drf", "cluster1", 0.2 / 1.25},
+		{"weighted-drf", "cluster1", 0.2 / 2},
+		{"weighted-drf", "cluster2", 0.1},
+		// cluster1 provides 15 of 20 cpus.
+		{"proportional", "cluster1", 0.2 / 0.75},
+		{"proportional", "cluster2", 0.1 / 0.5},
+	}
+	for _, test := range tests {
+		t.Run(test.policy+"/"+test.cluster, func(t *testing.T) {
+			clearTables(t)
+			RegisterCluster(types.Cluster{Id: "cluster1", Weight: 2, TotalResource: testResource(10000, 10240)})
+			RegisterCluster(types.Cluster{Id: "cluster2", TotalResource: testResource(10000, 10240)})
+			allocatedResource["cluster1"] = testResource(4000, 1024)
+			contributedResource["cluster1"] = testResource(5000, 0)
+			allocatedResource["cluster2"] = testResource(2000, 1024)
+			if err := SetFairnessPolicy(test.policy); err != nil {
+				t.Fatal(err)
+			}
+			if got := getClusterShare(test.cluster); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("share %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPolicyHooksFollowTheLedgers(t *testing.T) {
+	clearTables(t)
+	RegisterCluster(types.Cluster{Id: "cluster1", TotalResource: testResource(10000, 10240)})
+	RegisterCluster(types.Cluster{Id: "cluster2", TotalResource: testResource(10000, 10240)})
+	if err := SetFairnessPolicy("contribution-drf"); err != nil {
+		t.Fatal(err)
+	}
+	check := func(when string) {
+		t.Helper()
+		for _, id := range []string{"cluster1", "cluster2"} {
+			if got, want := clustersShare[id], policy.Share(id); got != want {
+				t.Errorf("%s: share of %s is %v, want %v", when, id, got, want)
+			}
+		}
+	}
+	lent := testInterPod("cluster2", "p", 2000, 1024)
+	place(lent, "cluster1", "", 1)
+	check("after cluster1 lent")
+	borrowed := testInterPod("cluster1", "q", 4000, 1024)
+	place(borrowed, "cluster2", "", 1)
+	check("after cluster2 lent")
+	releasePod(lent)
+	check("after a release")
+}
+
+func TestFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		selector  map[string]string
+		unhealthy string
+		cordoned  string
+		want      string
+	}{
+		{"lowest share", nil, "", "", "cluster3"},
+		{"node selector", map[string]string{"gpu": "true"}, "", "", "cluster2"},
+		{"unhealthy cluster", nil, "cluster3", "", "cluster2"},
+		{"cordoned cluster", nil, "", "cluster3", "cluster2"},
+		{"no cluster fits", map[string]string{"zone": "none"}, "", "", "cluster1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearTables(t)
+			for i, id := range []string{"cluster1", "cluster2", "cluster3"} {
+				node := types.Node{Name: "node1", Resource: testResource(10000, 10240)}
+				if id == "cluster2" {
+					node.Labels = map[string]string{"gpu": "true"}
+				}
+				RegisterCluster(types.Cluster{Id: id, TotalResource: node.Resource, Nodes: []types.Node{node}})
+				// cluster1 has the highest share, cluster3 the lowest.
+				allocatedResource[id] = testResource(int64(9000-3000*i), 0)
+			}
+			if err := SetFairnessPolicy("drf"); err != nil {
+				t.Fatal(err)
+			}
+			if test.unhealthy != "" {
+				markUnhealthy(clustersInfo[test.unhealthy])
+			}
+			if test.cordoned != "" {
+				setCordoned(test.cordoned, true)
+			}
+			pod := testInterPod("cluster1", "p", 1000, 1024)
+			pod.Constraints.NodeSelector = test.selector
+			if got := policy.Fallback(pod); got != test.want {
+				t.Errorf("fallback %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+	"types"
+
+	"github.com/golang/glog"
+)
+
+// reconcileGrace is how old a placement must be before its destination is
+// expected to run the pod, results take a moment to be delivered and acted on.
+const reconcileGrace = time.Minute
+
+// Reconcile repairs the ledgers with what a member reports, after changes
+// the coordinator missed while it was down or lost on a crash:
+//
+//   - pods of other clusters the member runs are charged if they are not,
+//   - placements on the member older than reconcileGrace that it does not
+//     run are released,
+//   - awaited pods that were placed get their result again, those neither
+//     placed nor queued are returned to be queued again.
+func Reconcile(state types.MemberState) (types.Reconciliation, []types.InterPod) {
+	mu.Lock()
+	defer mu.Unlock()
+	var r types.Reconciliation
+	now := clk.Now().Unix()
+	running := make(map[string]bool)
+	for _, pod := range state.Running {
+		pod := pod
+		key := podKey(pod)
+		running[key] = true
+		if _, ok := placements[key]; ok || pod.ClusterId == state.ClusterId {
+			continue
+		}
+		if _, ok := clustersInfo[pod.ClusterId]; !ok {
+			continue
+		}
+		commit(walEntry{Op: opPlace, Pod: &pod, Dest: state.ClusterId, Time: now})
+		glog.Infof("Reconcile: charge %s of %s running on %s.", pod.Name, pod.ClusterId, state.ClusterId)
+		r.Charged++
+	}
+	keys := make([]string, 0, len(placements))
+	for key := range placements {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		placement := placements[key]
+		if placement.DestClusterId != state.ClusterId || running[key] || now-placement.Time < int64(reconcileGrace/time.Second) {
+			continue
+		}
+		commit(walEntry{Op: opRelease, Pod: &placement.InterPod, Time: now})
+		glog.Infof("Reconcile: release %s of %s, %s does not run it.", placement.Name, placement.ClusterId, state.ClusterId)
+		r.Released++
+	}
+
+	var requeue []types.InterPod
+	for _, pod := range state.Awaiting {
+		interPod := types.InterPod{Pod: pod, ClusterId: state.ClusterId}
+		key := podKey(interPod)
+		if placement, ok := placements[key]; ok {
+			r.Results = append(r.Results, resultFor(pod, clustersInfo[placement.DestClusterId]))
+			continue
+		}
+		pendingLock.Lock()
+		_, queued := pending[key]
+		pendingLock.Unlock()
+		if !queued {
+			glog.Infof("Reconcile: queue %s of %s again.", pod.Name, state.ClusterId)
+			requeue = append(requeue, interPod)
+		}
+	}
+	r.Requeued = len(requeue)
+	return r, requeue
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"clock"
+	"testing"
+	"time"
+	"types"
+)
+
+func TestReconcile(t *testing.T) {
+	// cluster2 is the destination of old, young and kept, cluster1 of back.
+	old := testInterPod("cluster1", "old", 1000, 1024)
+	young := testInterPod("cluster1", "young", 1000, 1024)
+	kept := testInterPod("cluster1", "kept", 1000, 1024)
+	back := testInterPod("cluster2", "back", 1000, 1024)
+	uncharged := testInterPod("cluster1", "uncharged", 1000, 1024)
+	own := testInterPod("cluster2", "own", 1000, 1024)
+	stranger := testInterPod("cluster9", "stranger", 1000, 1024)
+	queued := testInterPod("cluster1", "queued", 1000, 1024)
+	lost := testInterPod("cluster1", "lost", 1000, 1024)
+	tests := []struct {
+		name     string
+		state    types.MemberState
+		want     types.Reconciliation // Results only by count
+		placed   []types.InterPod
+		released []types.InterPod
+	}{
+		{
+			name:     "destination runs only some",
+			state:    types.MemberState{ClusterId: "cluster2", Running: []types.InterPod{kept}},
+			want:     types.Reconciliation{Released: 1},
+			placed:   []types.InterPod{young, kept, back},
+			released: []types.InterPod{old},
+		},
+		{
+			name:   "uncharged pods of registered clusters",
+			state:  types.MemberState{ClusterId: "cluster2", Running: []types.InterPod{old, young, kept, uncharged, own, stranger}},
+			want:   types.Reconciliation{Charged: 1},
+			placed: []types.InterPod{old, young, kept, back, uncharged},
+		},
+		{
+			name:   "awaited pods",
+			state:  types.MemberState{ClusterId: "cluster1", Running: []types.InterPod{back}, Awaiting: []types.Pod{old.Pod, queued.Pod, lost.Pod}},
+			want:   types.Reconciliation{Results: make([]types.ScheduleResult, 1), Requeued: 1},
+			placed: []types.InterPod{old, young, kept, back},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearTables(t)
+			now := time.Unix(10000, 0)
+			clk = clock.NewVirtual(now)
+			registerTestCluster("cluster1", 4000, 4096)
+			registerTestCluster("cluster2", 4000, 4096)
+			mu.Lock()
+			for _, pod := range []types.InterPod{old, young, kept, back} {
+				pod := pod
+				placedAt := now.Add(-2 * reconcileGrace)
+				if pod.Name == "young" {
+					placedAt = now.Add(-reconcileGrace / 2)
+				}
+				dest := "cluster2"
+				if pod.ClusterId == "cluster2" {
+					dest = "cluster1"
+				}
+				commit(walEntry{Op: opPlace, Pod: &pod, Dest: dest, Time: placedAt.Unix()})
+			}
+			mu.Unlock()
+			recordQueued(queued)
+
+			r, requeue := Reconcile(test.state)
+			if r.Charged != test.want.Charged || r.Released != test.want.Released || r.Requeued != test.want.Requeued || len(r.Results) != len(test.want.Results) {
+				t.Errorf("charged %d, released %d, requeued %d and %d results, want %d, %d, %d and %d",
+					r.Charged, r.Released, r.Requeued, len(r.Results),
+					test.want.Charged, test.want.Released, test.want.Requeued, len(test.want.Results))
+			}
+			if r.Requeued != len(requeue) {
+				t.Errorf("%d pods to requeue, reported %d", len(requeue), r.Requeued)
+			}
+			for _, pod := range requeue {
+				if pod.Name != "lost" {
+					t.Errorf("%s requeued, only lost was neither placed nor queued", pod.Name)
+				}
+			}
+			for _, result := range r.Results {
+				if result.Name != "old" || result.DestClusterId != "cluster2" {
+					t.Errorf("result for %s on %s, want old on cluster2", result.Name, result.DestClusterId)
+				}
+			}
+			for _, pod := range test.placed {
+				if _, ok := PlacementDest(pod); !ok {
+					t.Errorf("%s is not charged", pod.Name)
+				}
+			}
+			for _, pod := range test.released {
+				if _, ok := PlacementDest(pod); ok {
+					t.Errorf("%s is still charged", pod.Name)
+				}
+			}
+			if len(placements) != len(test.placed) {
+				t.Errorf("%d placements charged, want %d", len(placements), len(test.placed))
+			}
+		})
+	}
+}
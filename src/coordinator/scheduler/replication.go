@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"types"
+)
+
+// Coordinator replicas that do not lead follow the log of the leader: they
+// apply its entries to their own tables and log them under the same Seq in
+// their own state directory, so that whichever is elected next schedules
+// from the tables the last leader left. Replication is asynchronous, the
+// entries a leader logged just before it failed may be lost with it, which
+// members repair with Reconcile.
+
+// replicationBacklog is how many of the latest entries the leader keeps for
+// replicas, those further behind get a snapshot.
+const replicationBacklog = 4096
+
+var errNotPersisted = errors.New("the coordinator's tables are not persisted, run it with -state_dir")
+
+// Replication is what a replica needs to catch up with the leader.
+type Replication struct {
+	// Snapshot is the leader's tables, set if asked for or if the replica is
+	// too far behind or ahead for the entries alone.
+	Snapshot []byte
+	// Entries are the log entries after the snapshot or the replica's Seq.
+	Entries [][]byte
+}
+
+// Replicate returns what a replica that applied the log up to entry seq
+// needs to catch up, waiting up to wait for an entry if it is up to date.
+// full asks for a snapshot whatever seq is, as replicas do when they start
+// following: their own log may have come from another leader.
+func Replicate(seq uint64, full bool, wait time.Duration) (Replication, error) {
+	if store == nil {
+		return Replication{}, errNotPersisted
+	}
+	store.lock.Lock()
+	if !full && seq == store.seq {
+		appended := store.appended
+		store.lock.Unlock()
+		select {
+		case <-appended:
+		case <-time.After(wait):
+		}
+		store.lock.Lock()
+	}
+	first := store.seq - uint64(len(store.recent)) + 1
+	if !full && seq+1 >= first && seq <= store.seq {
+		r := Replication{Entries: append([][]byte(nil), store.recent[seq+1-first:]...)}
+		store.lock.Unlock()
+		return r, nil
+	}
+	store.lock.Unlock()
+
+	// the tables are read under mu, taken before the store's lock.
+	mu.Lock()
+	defer mu.Unlock()
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	data, err := store.encode()
+	return Replication{Snapshot: data}, err
+}
+
+// ReplicatedSeq is the last entry applied to the tables.
+func ReplicatedSeq() uint64 {
+	if store == nil {
+		return 0
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.seq
+}
+
+// Follow applies what Replicate returned on the leader. An entry that does
+// not follow the last one applied is an error, the replica must then start
+// over from a snapshot.
+func Follow(r Replication) error {
+	if store == nil {
+		return errNotPersisted
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if r.Snapshot != nil {
+		resetTables()
+		store.queues = make(map[string][]queuedPod)
+		store.recent = nil
+		if err := store.restore(r.Snapshot); err != nil {
+			return fmt.Errorf("replicated snapshot: %v", err)
+		}
+		for id := range clustersInfo {
+			computeClusterShare(id)
+		}
+		if err := store.persist(r.Snapshot); err != nil {
+			return err
+		}
+	}
+	for _, line := range r.Entries {
+		var e walEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("replicated entry: %v", err)
+		}
+		if e.Seq <= store.seq {
+			continue
+		}
+		if e.Seq != store.seq+1 {
+			return fmt.Errorf("replicated entry %d does not follow %d", e.Seq, store.seq)
+		}
+		if err := store.write(e, line); err != nil {
+			return err
+		}
+		apply(e)
+	}
+	return nil
+}
+
+// resetTables empties the tables before a replicated snapshot replaces them.
+// It is called with mu held.
+func resetTables() {
+	for _, cluster := range clustersInfo {
+		removeCluster(cluster)
+	}
+	for id := range placements {
+		delete(placements, id)
+	}
+	for name := range IdleNodes {
+		delete(IdleNodes, name)
+	}
+	for id := range cordoned {
+		delete(cordoned, id)
+	}
+	TotalResource = types.Resource{}
+}
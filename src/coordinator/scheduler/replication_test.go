@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"testing"
+	"types"
+)
+
+func TestFollow(t *testing.T) {
+	tests := []struct {
+		name string
+		// steps are what the replica follows, in order: "snapshot" is the
+		// leader's snapshot at entry 2, "entries" its entries after it,
+		// "late" those after entry 3.
+		steps   []string
+		wantErr bool
+	}{
+		{"snapshot and entries", []string{"snapshot", "entries"}, false},
+		{"entries again", []string{"snapshot", "entries", "entries"}, false},
+		{"entries in two parts", []string{"snapshot", "entries", "late"}, false},
+		{"missed entry", []string{"snapshot", "late"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearTables(t)
+			if err := OpenState(t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			RegisterCluster(types.Cluster{Id: "cluster1", TotalResource: testResource(4000, 4096)})
+			RegisterCluster(types.Cluster{Id: "cluster2", TotalResource: testResource(4000, 4096)})
+			full, err := Replicate(0, true, 0)
+			if err != nil || full.Snapshot == nil {
+				t.Fatalf("no snapshot replicated: %v", err)
+			}
+			pod := testInterPod("cluster1", "p", 1000, 1024)
+			other := testInterPod("cluster1", "q", 2000, 1024)
+			mu.Lock()
+			commit(walEntry{Op: opPlace, Pod: &pod, Dest: "cluster2"})
+			commit(walEntry{Op: opPlace, Pod: &other, Dest: "cluster2"})
+			mu.Unlock()
+			entries, err := Replicate(2, false, 0)
+			if err != nil || len(entries.Entries) != 2 {
+				t.Fatalf("%d entries replicated, want 2: %v", len(entries.Entries), err)
+			}
+			late, err := Replicate(3, false, 0)
+			if err != nil || len(late.Entries) != 1 {
+				t.Fatalf("%d entries replicated, want 1: %v", len(late.Entries), err)
+			}
+			leaderAllocated := allocatedResource["cluster1"]
+
+			// the replica starts over from its own, empty, state.
+			crash(t)
+			if err := OpenState(t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+			steps := map[string]Replication{"snapshot": full, "entries": entries, "late": late}
+			for _, step := range test.steps {
+				err = Follow(steps[step])
+				if err != nil {
+					break
+				}
+			}
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("followed entries that do not follow the last one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ReplicatedSeq(); got != 4 {
+				t.Errorf("replica at entry %d, want 4", got)
+			}
+			if len(clustersInfo) != 2 || len(placements) != 2 {
+				t.Errorf("replica has %d clusters and %d placements, want 2 and 2", len(clustersInfo), len(placements))
+			}
+			if !allocatedResource["cluster1"].Equal(leaderAllocated) {
+				t.Errorf("replica allocated %v to cluster1, the leader %v", allocatedResource["cluster1"], leaderAllocated)
+			}
+		})
+	}
+}
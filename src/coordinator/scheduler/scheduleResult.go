@@ -1,8 +1,7 @@
 package scheduler
 
 import (
-	"os"
-	"strconv"
+	"events"
 	"time"
 	"types"
 
@@ -12,6 +11,8 @@ import (
 var (
 	clusterDataQ chan types.UserData
 	startTime    int64
+	// eventsConfig is where HandleClusterData writes federationData.
+	eventsConfig = events.Config{Dir: "..", Format: events.FormatCSV, SyncInterval: -1}
 )
 
 func init() {
@@ -19,18 +20,45 @@ func init() {
 	startTime = time.Now().Unix()
 }
 
+// SetEvents makes HandleClusterData write federationData as config says.
+func SetEvents(config events.Config) {
+	eventsConfig = config
+}
+
+// HandleClusterData writes the records queued by Schedule. They are dropped
+// if the file cannot be opened, the scheduler keeps running.
 func HandleClusterData() {
-	filename := "../federationData.csv"
-	fd, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	sink, err := events.Open("federationData", eventsConfig)
 	if err != nil {
-		glog.Error()
+		glog.Errorf("federationData records are dropped: %v", err)
 	}
-	var content string
 	for data := range clusterDataQ {
-		content = data.Uid + "," + strconv.FormatInt(data.CurrentTime-startTime, 10) + "," + strconv.FormatFloat(data.Share, 'f', 4, 64) + "," +
-			strconv.FormatInt(data.MilliCpu, 10) + "," + strconv.FormatInt(data.Memory, 10) + "," + "\n"
-		buf := []byte(content)
-		fd.Write(buf)
+		if sink == nil {
+			continue
+		}
+		if err := sink.Write(clusterRecord(data)); err != nil {
+			glog.Error(err)
+		}
+	}
+}
+
+// FlushClusterData writes the records queued so far to sink without waiting
+// for more, for callers that run ScheduleOnce instead of HandleClusterData.
+func FlushClusterData(sink *events.Sink) error {
+	for len(clusterDataQ) > 0 {
+		if err := sink.Write(clusterRecord(<-clusterDataQ)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clusterRecord(data types.UserData) events.ClusterShareRecord {
+	return events.ClusterShareRecord{
+		Cluster:  data.Uid,
+		Time:     data.CurrentTime - startTime,
+		Share:    data.Share,
+		MilliCpu: data.MilliCpu,
+		Memory:   data.Memory,
 	}
-	defer fd.Close()
 }
@@ -1,10 +1,16 @@
 package scheduler
 
 import (
+	"clock"
 	"container/heap"
-	"net/rpc"
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"sync"
 	"time"
 	"types"
+	"types/federationpb"
 
 	"github.com/golang/glog"
 )
@@ -15,8 +21,28 @@ var (
 	clustersActiveQ   chan string
 	clustersPodsQ     map[string]chan types.InterPod
 	clustersInfo      map[string]types.Cluster
+	releasedPodCh     chan types.InterPod
 	IdleNodes         map[string]types.InterNode
 	TotalResource     types.Resource
+	// mu guards the cluster tables above against concurrent RPC handlers.
+	mu sync.Mutex
+
+	clk           clock.Clock   = clock.Real{}
+	deliverResult ResultHandler = uploadResult
+)
+
+// ResultHandler hands the destination chosen for pod to its source cluster.
+type ResultHandler func(pod types.Pod, source, dest types.Cluster)
+
+const (
+	defaultClusterPort = "4321"
+)
+
+var (
+	// rpcTimeout is the deadline of gRPC calls to members.
+	rpcTimeout = 10 * time.Second
+	// tlsConfig authenticates the coordinator to members, nil without TLS.
+	tlsConfig *tls.Config
 )
 
 func init() {
@@ -24,140 +50,359 @@ func init() {
 	clustersActiveQ = make(chan string, 10)
 	clustersPodsQ = make(map[string]chan types.InterPod)
 	clustersInfo = make(map[string]types.Cluster)
+	releasedPodCh = make(chan types.InterPod, 100)
 	IdleNodes = make(map[string]types.InterNode)
 }
 
+// SetClock makes the scheduler read and wait on c instead of the wall clock.
+func SetClock(c clock.Clock) {
+	clk = c
+	startTime = c.Now().Unix()
+}
+
+// SetRpcTimeout sets the deadline of gRPC calls to members.
+func SetRpcTimeout(d time.Duration) {
+	rpcTimeout = d
+}
+
+// SetTLS makes calls to members use mutual TLS with config.
+func SetTLS(config *tls.Config) {
+	tlsConfig = config
+}
+
+// SetResultHandler replaces the RPC that returns schedule results to the
+// source cluster. It is called without mu held, after the placement is
+// committed.
+func SetResultHandler(h ResultHandler) {
+	deliverResult = h
+}
+
 func RegisterCluster(cluster types.Cluster) {
-	var res types.Resource
-	allocatedResource[cluster.Id] = res
-	contributedResource[cluster.Id] = res
-	clustersShare[cluster.Id] = 0
+	mu.Lock()
+	defer mu.Unlock()
+	commit(walEntry{Op: opRegister, Cluster: &cluster})
+}
+
+func registerCluster(cluster types.Cluster, now int64) {
+	if old, ok := clustersInfo[cluster.Id]; ok && old.Healthy {
+		// re-registration replaces the previous incarnation of the cluster.
+		markUnhealthy(old)
+	}
+	cluster.LastHeartbeat = now
+	cluster.Healthy = true
+	// the ledgers of a cluster that registers again follow its placements,
+	// which it keeps.
+	if _, ok := clustersInfo[cluster.Id]; !ok {
+		var res types.Resource
+		allocatedResource[cluster.Id] = res
+		contributedResource[cluster.Id] = res
+		clustersShare[cluster.Id] = 0
+	}
 	clustersInfo[cluster.Id] = cluster
-	TotalResource.Memory += cluster.TotalResource.Memory
-	TotalResource.MilliCpu += cluster.TotalResource.MilliCpu
+	TotalResource = TotalResource.Add(cluster.TotalResource)
+	recordCluster(cluster.Id)
 	glog.Info("TotalResource:", TotalResource)
 }
 
-func UpdateCluster(cluster types.Cluster) {
+// UpdateCluster records a heartbeat of cluster, false if it is not
+// registered.
+func UpdateCluster(cluster types.Cluster) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := clustersInfo[cluster.Id]; !ok {
+		glog.Warningf("Heartbeat from unregistered cluster:%s", cluster.Id)
+		return false
+	}
+	commit(walEntry{Op: opUpdate, Cluster: &cluster})
+	return true
+}
+
+func updateCluster(cluster types.Cluster, now int64) {
+	info := clustersInfo[cluster.Id]
+	info.LastHeartbeat = now
+	if !info.Healthy {
+		info.Healthy = true
+		TotalResource = TotalResource.Add(info.TotalResource)
+		glog.Infof("Cluster %s is healthy again, TotalResource:%v", cluster.Id, TotalResource)
+	}
+	// members that predate capacity reporting keep what they registered.
+	if cluster.ReportsCapacity && !cluster.TotalResource.Equal(info.TotalResource) {
+		if info.Healthy {
+			TotalResource = TotalResource.Add(cluster.TotalResource).Sub(info.TotalResource)
+		}
+		info.TotalResource = cluster.TotalResource
+		glog.Infof("Cluster %s's totalResource:%v, TotalResource:%v", cluster.Id, info.TotalResource, TotalResource)
+	}
+	if cluster.Nodes != nil {
+		info.Nodes = cluster.Nodes
+	}
+	clustersInfo[cluster.Id] = info
+	reported := make(map[string]bool)
 	for _, node := range cluster.IdleNodes {
 		nodeName := cluster.Id + node.Name
+		reported[nodeName] = true
 		idleNode, ok := IdleNodes[nodeName]
-		if !ok || idleNode.IdleResource.MilliCpu != node.IdleResource.MilliCpu || idleNode.IdleResource.Memory != node.IdleResource.Memory {
+		if !ok || !idleNode.IdleResource.Equal(node.IdleResource) {
 			IdleNodes[nodeName] = node
 			glog.Infof("Update %s : %s %v", cluster.Id, node.Name, node.IdleResource)
 		}
 	}
+	// nodes missing from the report are full, gone or no longer schedulable.
+	for nodeName, node := range IdleNodes {
+		if node.ClusterId == cluster.Id && !reported[nodeName] {
+			delete(IdleNodes, nodeName)
+		}
+	}
 }
 
 func DispatchPods(pendingPodCh chan types.InterPod) {
 	for pod := range pendingPodCh {
-		value, ok := clustersPodsQ[pod.ClusterId]
-		if !ok {
-			clustersPodsQ[pod.ClusterId] = make(chan types.InterPod, 20)
-			value = clustersPodsQ[pod.ClusterId]
-		}
-		if len(value) == 0 {
-			clustersActiveQ <- pod.ClusterId
-		}
-		value <- pod
+		QueuePod(pod)
 	}
 }
 
+// QueuePod adds pod to the queue of its source cluster. Members upload a
+// pod again when they got no answer, a pod queued or placed already is
+// dropped.
+func QueuePod(pod types.InterPod) {
+	if known(pod) {
+		glog.Infof("Drop %s of %s, it is queued or placed already.", pod.Name, pod.ClusterId)
+		return
+	}
+	value, ok := clustersPodsQ[pod.ClusterId]
+	if !ok {
+		clustersPodsQ[pod.ClusterId] = make(chan types.InterPod, 20)
+		value = clustersPodsQ[pod.ClusterId]
+	}
+	commit(walEntry{Op: opQueue, Pod: &pod})
+	if len(value) == 0 {
+		clustersActiveQ <- pod.ClusterId
+	}
+	recordQueued(pod)
+	value <- pod
+	recordQueue(pod.ClusterId, value)
+}
+
+// known reports whether pod is queued or placed.
+func known(pod types.InterPod) bool {
+	key := podKey(pod)
+	pendingLock.Lock()
+	_, queued := pending[key]
+	pendingLock.Unlock()
+	if queued {
+		return true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_, placed := placements[key]
+	return placed
+}
+
+// QueueFull reports whether QueuePod would block on the cluster's queue.
+func QueueFull(clusterId string) bool {
+	podsQ, ok := clustersPodsQ[clusterId]
+	return ok && len(podsQ) == cap(podsQ)
+}
+
+// ReleasePod queues a finished cross-cluster pod; its resources are given
+// back by the Schedule loop.
+func ReleasePod(pod types.InterPod) {
+	releasedPodCh <- pod
+}
+
 func Schedule() {
 	for {
-		// fix clustersPriorityQ
-		clustersActiveQLen := len(clustersActiveQ)
-		for i := 0; i < clustersActiveQLen; i++ {
-			clusterId := <-clustersActiveQ
-			present, ok := clustersPresent[clusterId]
-			if ok && present {
-				continue
-			} else {
-				clustersPresent[clusterId] = true
-				cluster := &types.Cluster{
-					Id:       clusterId,
-					Priority: getClusterShare(clusterId),
-				}
-				heap.Push(&clustersPriorityQ, cluster)
+		ScheduleOnce()
+		clk.Sleep(3 * time.Second)
+	}
+}
+
+// ScheduleOnce is one round of Schedule: it gives back released pods and
+// places at most one pod of the cluster with the lowest share.
+func ScheduleOnce() {
+	// release finished pods and reorder clustersPriorityQ
+	releasedPodChLen := len(releasedPodCh)
+	if releasedPodChLen > 0 {
+		mu.Lock()
+		for i := 0; i < releasedPodChLen; i++ {
+			pod := <-releasedPodCh
+			if _, ok := placements[podKey(pod)]; ok {
+				commit(walEntry{Op: opRelease, Pod: &pod})
+			}
+		}
+		for _, cluster := range clustersPriorityQ {
+			cluster.Priority = getClusterShare(cluster.Id)
+		}
+		heap.Init(&clustersPriorityQ)
+		mu.Unlock()
+	}
+
+	// fix clustersPriorityQ
+	clustersActiveQLen := len(clustersActiveQ)
+	for i := 0; i < clustersActiveQLen; i++ {
+		clusterId := <-clustersActiveQ
+		present, ok := clustersPresent[clusterId]
+		if ok && present {
+			continue
+		} else {
+			clustersPresent[clusterId] = true
+			cluster := &types.Cluster{
+				Id:       clusterId,
+				Priority: getClusterShare(clusterId),
 			}
+			heap.Push(&clustersPriorityQ, cluster)
 		}
+	}
 
-		// schedule pod
-		if len(clustersPriorityQ) > 0 {
-			topCluster := heap.Pop(&clustersPriorityQ).(*types.Cluster)
-			select {
-			case firstPod := <-clustersPodsQ[topCluster.Id]:
-				glog.Info("=============================")
-				glog.Info("Before Schedule()")
-				printShare()
-				destClusterId := schedulePod(firstPod)
-				if destClusterId != firstPod.ClusterId {
-					fixContributedResource(firstPod, destClusterId)
-					topCluster.Priority = fixClusterShare(firstPod)
-				}
-				heap.Push(&clustersPriorityQ, topCluster)
-				glog.Info("After Schedule()")
-				printShare()
-				glog.Info("=============================")
-				clusterData := types.UserData{
-					Uid:         firstPod.Uid,
-					CurrentTime: time.Now().Unix(),
-					Share:       topCluster.Priority,
-					Resource:    allocatedResource[firstPod.Uid],
-				}
-				clusterDataQ <- clusterData
-			default:
-				clustersPresent[topCluster.Id] = false
+	// schedule pod
+	if len(clustersPriorityQ) > 0 {
+		topCluster := heap.Pop(&clustersPriorityQ).(*types.Cluster)
+		select {
+		case firstPod := <-clustersPodsQ[topCluster.Id]:
+			recordQueue(topCluster.Id, clustersPodsQ[topCluster.Id])
+			mu.Lock()
+			glog.Info("=============================")
+			glog.Info("Before Schedule()")
+			printShare()
+			destClusterId := schedulePod(firstPod)
+			if destClusterId != firstPod.ClusterId {
+				topCluster.Priority = getClusterShare(firstPod.ClusterId)
+			}
+			source, dest := clustersInfo[firstPod.ClusterId], clustersInfo[destClusterId]
+			heap.Push(&clustersPriorityQ, topCluster)
+			glog.Info("After Schedule()")
+			printShare()
+			glog.Info("=============================")
+			clusterData := types.UserData{
+				Uid:         firstPod.Uid,
+				CurrentTime: clk.Now().Unix(),
+				Share:       topCluster.Priority,
+				Resource:    allocatedResource[firstPod.Uid],
 			}
+			mu.Unlock()
+			// the result is an RPC to the source, made without holding mu.
+			deliverResult(firstPod.Pod, source, dest)
+			clusterDataQ <- clusterData
+		default:
+			clustersPresent[topCluster.Id] = false
 		}
-		time.Sleep(3 * time.Second)
 	}
 }
 
+// schedulePod places a queued pod and returns its destination, which the
+// caller tells the source cluster once the placement is committed.
 func schedulePod(pod types.InterPod) string {
-	for {
-		for nodeName, node := range IdleNodes {
-			if node.IdleResource.Memory >= pod.RequestMemory && node.IdleResource.MilliCpu >= pod.RequestMilliCpu {
-				uploadResult(pod.Pod, clustersInfo[pod.ClusterId].Ip, clustersInfo[node.ClusterId].Ip)
-				glog.Infof("Successfully schedule %s of %s to %s.", pod.Name, pod.ClusterId, node.ClusterId)
-				node.IdleResource.Memory -= pod.RequestMemory
-				node.IdleResource.MilliCpu -= pod.RequestMilliCpu
-				IdleNodes[nodeName] = node
-				glog.Infof("Update %s : %s %v", node.ClusterId, node.Name, node.IdleResource)
-				return node.ClusterId
-			}
+	// walk the nodes in a fixed order so that runs are reproducible.
+	nodeNames := make([]string, 0, len(IdleNodes))
+	for nodeName := range IdleNodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		node := IdleNodes[nodeName]
+		if !placeable(node.ClusterId, pod) {
+			continue
 		}
-		clusterId := pod.ClusterId
-		share := clustersShare[clusterId]
-		destClusterId := clusterId
-		minShare := share
-		for c, s := range clustersShare {
-			if s < minShare {
-				minShare = s
-				destClusterId = c
-			}
+		if pod.Request().Fits(node.IdleResource) && pod.Constraints.Fits(node.Node) {
+			commit(walEntry{Op: opPlace, Pod: &pod, Dest: node.ClusterId, Node: nodeName, Queued: true})
+			recordPlaced(pod, node.ClusterId, node.Name, "first idle node, by name, with room and matching constraints")
+			glog.Infof("Successfully schedule %s of %s to %s.", pod.Name, pod.ClusterId, node.ClusterId)
+			glog.Infof("Update %s : %s %v", node.ClusterId, node.Name, IdleNodes[nodeName].IdleResource)
+			return node.ClusterId
 		}
-		uploadResult(pod.Pod, clustersInfo[pod.ClusterId].Ip, clustersInfo[destClusterId].Ip)
-		glog.Infof("Successfully schedule %s of %s to %s.", pod.Name, pod.ClusterId, destClusterId)
-		return destClusterId
+	}
+	destClusterId := policy.Fallback(pod)
+	commit(walEntry{Op: opPlace, Pod: &pod, Dest: destClusterId, Queued: true})
+	recordPlaced(pod, destClusterId, "", "no idle node fits, cluster chosen by the fairness policy")
+	glog.Infof("Successfully schedule %s of %s to %s.", pod.Name, pod.ClusterId, destClusterId)
+	return destClusterId
+}
+
+// place charges a placement to IdleNodes, where nodeName is the idle node
+// pod fit on, if any, and to the ledgers.
+func place(pod types.InterPod, destClusterId, nodeName string, now int64) {
+	if node, ok := IdleNodes[nodeName]; ok {
+		node.IdleResource = node.IdleResource.Sub(pod.Request())
+		IdleNodes[nodeName] = node
+	}
+	if destClusterId != pod.ClusterId {
+		fixContributedResource(pod, destClusterId, now)
+		fixClusterShare(pod)
+		policy.Allocated(pod, destClusterId)
 	}
 }
 
-func uploadResult(pod types.Pod, sourceIp, destIp string) {
-	result := &types.ScheduleResult{
-		Pod:    pod,
-		DestIp: destIp,
+// resultFor tells the source cluster of pod to create it on dest.
+func resultFor(pod types.Pod, dest types.Cluster) types.ScheduleResult {
+	return types.ScheduleResult{
+		Pod:           pod,
+		DestIp:        dest.Ip,
+		DestPort:      clusterPort(dest),
+		DestVersion:   dest.Version,
+		DestClusterId: dest.Id,
 	}
-	client, err := rpc.DialHTTP("tcp", sourceIp+":4321")
-	if err == nil {
-		glog.Info("ReturnScheduleResult:", result, " to ", sourceIp)
+}
+
+func uploadResult(pod types.Pod, source, dest types.Cluster) {
+	result := resultFor(pod, dest)
+	addr := net.JoinHostPort(source.Ip, clusterPort(source))
+	config := federationpb.WithServerName(tlsConfig, source.Id)
+	var err error
+	if source.Version > 0 {
+		err = returnResultGrpc(addr, config, result)
 	} else {
+		err = returnResultRpc(addr, config, result)
+	}
+	if err != nil {
+		rpcErrorCounter.WithLabelValues("Member.ReturnScheduleResult").Inc()
 		glog.Info(err)
+		return
 	}
+	glog.Info("ReturnScheduleResult:", result, " to ", source.Ip)
+}
 
-	var reply int
-	err = client.Call("Server.ReturnScheduleResult", result, &reply)
+func returnResultGrpc(addr string, config *tls.Config, result types.ScheduleResult) error {
+	conn, err := federationpb.Dial(addr, config)
 	if err != nil {
-		glog.Info(err)
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+	_, err = federationpb.NewMemberClient(conn).ReturnScheduleResult(ctx, federationpb.EncodeScheduleResult(result))
+	return err
+}
+
+// returnResultRpc calls members that predate the gRPC protocol.
+func returnResultRpc(addr string, config *tls.Config, result types.ScheduleResult) error {
+	client, err := federationpb.DialRPC(addr, config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply int
+	return client.Call("Server.ReturnScheduleResult", &result, &reply)
+}
+
+// clusterPort returns the port a member advertised, falling back to the
+// default for members that do not send one.
+func clusterPort(cluster types.Cluster) string {
+	if cluster.Port == "" {
+		return defaultClusterPort
+	}
+	return cluster.Port
+}
+
+// canSatisfy reports whether cluster has a node that pod's constraints
+// allow, assuming so for members that do not report their nodes.
+func canSatisfy(cluster types.Cluster, pod types.InterPod) bool {
+	if len(cluster.Nodes) == 0 {
+		return true
+	}
+	for _, node := range cluster.Nodes {
+		if pod.Constraints.Fits(node) {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+	"types"
+)
+
+// emptyTables drops everything the coordinator knows, as a restart without
+// state does.
+func emptyTables() {
+	clustersPriorityQ = nil
+	clustersPresent = make(map[string]bool)
+	clustersActiveQ = make(chan string, 10)
+	clustersPodsQ = make(map[string]chan types.InterPod)
+	clustersInfo = make(map[string]types.Cluster)
+	releasedPodCh = make(chan types.InterPod, 100)
+	IdleNodes = make(map[string]types.InterNode)
+	TotalResource = types.Resource{}
+	allocatedResource = make(map[string]types.Resource)
+	contributedResource = make(map[string]types.Resource)
+	clustersShare = make(map[string]float64)
+	placements = make(map[string]types.Placement)
+	cordoned = make(map[string]bool)
+	pending = make(map[string]pendingPod)
+	recentPlacements = nil
+}
+
+// clearTables empties the tables for a test. Results are dropped, and the
+// policy, clock and store are put back when it ends.
+func clearTables(t *testing.T) {
+	t.Helper()
+	emptyTables()
+	savedPolicy, savedClock, savedDeliver, savedStore := policy, clk, deliverResult, store
+	deliverResult = func(pod types.Pod, source, dest types.Cluster) {}
+	t.Cleanup(func() {
+		if store != nil && store != savedStore {
+			store.wal.Close()
+		}
+		policy, clk, deliverResult, store = savedPolicy, savedClock, savedDeliver, savedStore
+	})
+}
+
+func testResource(milliCpu, memoryMi int64) types.Resource {
+	return types.FromQuantities(map[string]int64{
+		types.ResourceCpu:    milliCpu,
+		types.ResourceMemory: memoryMi * 1024 * 1024,
+	})
+}
+
+func testInterPod(clusterId, name string, milliCpu, memoryMi int64) types.InterPod {
+	return types.InterPod{
+		Pod:       types.Pod{Name: name, Uid: "tenant1", Requests: testResource(milliCpu, memoryMi)},
+		ClusterId: clusterId,
+	}
+}
+
+// registerTestCluster registers id with an idle node of its whole capacity.
+func registerTestCluster(id string, milliCpu, memoryMi int64) {
+	capacity := testResource(milliCpu, memoryMi)
+	node := types.InterNode{Node: types.Node{Name: "node1", Resource: capacity}, ClusterId: id, IdleResource: capacity}
+	RegisterCluster(types.Cluster{Id: id, TotalResource: capacity})
+	UpdateCluster(types.Cluster{Id: id, IdleNodes: []types.InterNode{node}})
+}
+
+func TestClusterPort(t *testing.T) {
+	tests := []struct {
+		port string
+		want string
+	}{
+		{"", defaultClusterPort},
+		{"5000", "5000"},
+	}
+	for _, test := range tests {
+		if got := clusterPort(types.Cluster{Port: test.port}); got != test.want {
+			t.Errorf("clusterPort of port %q is %q, want %q", test.port, got, test.want)
+		}
+	}
+}
+
+func TestScheduleOnceDeliversResultsWithoutLock(t *testing.T) {
+	clearTables(t)
+	registerTestCluster("cluster1", 1000, 1024)
+	registerTestCluster("cluster2", 4000, 4096)
+	delivered := make(chan string, 1)
+	SetResultHandler(func(pod types.Pod, source, dest types.Cluster) {
+		// a slow result must not keep RPC handlers from the tables.
+		done := make(chan struct{})
+		go func() {
+			mu.Lock()
+			mu.Unlock()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("result delivered with mu held")
+		}
+		delivered <- source.Id + "->" + dest.Id
+	})
+
+	QueuePod(testInterPod("cluster1", "p", 2000, 1024))
+	ScheduleOnce()
+	<-clusterDataQ
+
+	select {
+	case got := <-delivered:
+		if got != "cluster1->cluster2" {
+			t.Errorf("result %s, want cluster1->cluster2", got)
+		}
+	default:
+		t.Fatal("no result delivered")
+	}
+}
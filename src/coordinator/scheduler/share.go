@@ -10,12 +10,16 @@ var (
 	allocatedResource   map[string]types.Resource
 	contributedResource map[string]types.Resource
 	clustersShare       map[string]float64
+	// placements holds the cross-cluster pods charged to the ledgers above,
+	// keyed by podKey, until their release.
+	placements map[string]types.Placement
 )
 
 func init() {
 	allocatedResource = make(map[string]types.Resource)
 	contributedResource = make(map[string]types.Resource)
 	clustersShare = make(map[string]float64)
+	placements = make(map[string]types.Placement)
 }
 
 func printShare() {
@@ -27,22 +31,59 @@ func printShare() {
 }
 
 func fixClusterShare(pod types.InterPod) float64 {
-	allocRes := allocatedResource[pod.ClusterId]
-	allocRes.MilliCpu += pod.RequestMilliCpu
-	allocRes.Memory += pod.RequestMemory
-	allocatedResource[pod.ClusterId] = allocRes
-	contRes := contributedResource[pod.ClusterId]
-	dominantContribution := Max(float64(contRes.MilliCpu)/float64(TotalResource.MilliCpu), float64(contRes.Memory)/float64(TotalResource.Memory))
-	dominantShare := Max(float64(allocRes.MilliCpu)/float64(TotalResource.MilliCpu), float64(allocRes.Memory)/float64(TotalResource.Memory)) / (1 + dominantContribution)
-	clustersShare[pod.ClusterId] = dominantShare
-	return dominantShare
-}
-
-func fixContributedResource(pod types.InterPod, clusterId string) {
-	res := contributedResource[clusterId]
-	res.MilliCpu += pod.RequestMilliCpu
-	res.Memory += pod.RequestMemory
-	contributedResource[clusterId] = res
+	allocatedResource[pod.ClusterId] = allocatedResource[pod.ClusterId].Add(pod.Request())
+	return computeClusterShare(pod.ClusterId)
+}
+
+func computeClusterShare(clusterId string) float64 {
+	share := policy.Share(clusterId)
+	clustersShare[clusterId] = share
+	recordCluster(clusterId)
+	return share
+}
+
+func fixContributedResource(pod types.InterPod, clusterId string, now int64) {
+	contributedResource[clusterId] = contributedResource[clusterId].Add(pod.Request())
+	placements[podKey(pod)] = types.Placement{
+		InterPod:      pod,
+		DestClusterId: clusterId,
+		Time:          now,
+	}
+	recordCluster(clusterId)
+}
+
+// releasePod gives back what fixClusterShare and fixContributedResource
+// charged for pod. It returns false if the pod was never charged or has
+// already been released.
+func releasePod(pod types.InterPod) bool {
+	key := podKey(pod)
+	placement, ok := placements[key]
+	if !ok {
+		return false
+	}
+	delete(placements, key)
+	if allocRes, ok := allocatedResource[placement.ClusterId]; ok {
+		allocatedResource[placement.ClusterId] = allocRes.Sub(placement.Request())
+	}
+	if contRes, ok := contributedResource[placement.DestClusterId]; ok {
+		contributedResource[placement.DestClusterId] = contRes.Sub(placement.Request())
+	}
+	policy.Released(placement)
+	glog.Infof("Release %s of %s from %s.", pod.Name, placement.ClusterId, placement.DestClusterId)
+	return true
+}
+
+// PlacementDest returns the cluster pod was placed on by the coordinator,
+// false if it is not charged to the ledgers.
+func PlacementDest(pod types.InterPod) (string, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	placement, ok := placements[podKey(pod)]
+	return placement.DestClusterId, ok
+}
+
+func podKey(pod types.InterPod) string {
+	return pod.ClusterId + "/" + pod.Uid + "/" + pod.Name
 }
 
 func getClusterShare(id string) float64 {
@@ -0,0 +1,428 @@
+package scheduler
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"types"
+
+	"github.com/golang/glog"
+)
+
+// The tables of the coordinator are persisted in a state directory as a
+// write-ahead log of the changes made to them, wal.jsonl, and a snapshot of
+// the tables up to some entry of the log, snapshot.json. Every change is
+// committed, logged and synced before it is applied and before anyone learns
+// of it, so replaying the snapshot and the entries after it rebuilds the
+// tables as they were. A snapshot truncates the log.
+//
+// Entries record outcomes, not requests: a placement is logged with its
+// destination, so that replaying it neither asks the fairness policy again
+// nor calls members.
+const (
+	walFile      = "wal.jsonl"
+	snapshotFile = "snapshot.json"
+)
+
+const (
+	opRegister   = "register"
+	opUpdate     = "update"
+	opExpire     = "expire"
+	opDeregister = "deregister"
+	opCordon     = "cordon"
+	opQueue      = "queue"
+	opPlace      = "place"
+	opRelease    = "release"
+)
+
+// walEntry is one change to the tables. Node is the IdleNodes key of the
+// node a placement fit on, Queued is set for pods placed from
+// clustersPodsQ.
+type walEntry struct {
+	Seq       uint64          `json:"seq"`
+	Time      int64           `json:"time"` // unix seconds
+	Op        string          `json:"op"`
+	Cluster   *types.Cluster  `json:"cluster,omitempty"`
+	ClusterId string          `json:"clusterId,omitempty"`
+	Cordoned  bool            `json:"cordoned,omitempty"`
+	Pod       *types.InterPod `json:"pod,omitempty"`
+	Dest      string          `json:"dest,omitempty"`
+	Node      string          `json:"node,omitempty"`
+	Queued    bool            `json:"queued,omitempty"`
+}
+
+// queuedPod is a pod in clustersPodsQ and when it was queued.
+type queuedPod struct {
+	Pod      types.InterPod `json:"pod"`
+	QueuedAt int64          `json:"queuedAt"` // unix seconds
+}
+
+// snapshot holds the tables as of entry Seq of the log. Shares and
+// TotalResource are derived from them.
+type snapshot struct {
+	Seq         uint64                     `json:"seq"`
+	Clusters    map[string]types.Cluster   `json:"clusters"`
+	Allocated   map[string]types.Resource  `json:"allocated"`
+	Contributed map[string]types.Resource  `json:"contributed"`
+	Placements  map[string]types.Placement `json:"placements"`
+	IdleNodes   map[string]types.InterNode `json:"idleNodes"`
+	Cordoned    map[string]bool            `json:"cordoned"`
+	Queues      map[string][]queuedPod     `json:"queues"`
+}
+
+// stateStore is the state directory of the coordinator. Its lock is taken
+// after mu, queue entries are committed without mu.
+type stateStore struct {
+	dir string
+
+	lock sync.Mutex
+	wal  *os.File
+	seq  uint64
+	// queues mirrors clustersPodsQ for snapshots, channels cannot be read
+	// without taking the pods.
+	queues map[string][]queuedPod
+	// recent are the last replicationBacklog entries as logged, the last
+	// one is entry seq. appended is closed and replaced on every entry.
+	recent   [][]byte
+	appended chan struct{}
+}
+
+// store is nil if the tables are not persisted.
+var store *stateStore
+
+// commit logs e, if the tables are persisted, and applies it. It is called
+// with mu held, except for queue entries. A change that cannot be logged
+// stops the coordinator, it would be lost on the next restart.
+func commit(e walEntry) {
+	if e.Time == 0 {
+		e.Time = clk.Now().Unix()
+	}
+	if store != nil {
+		if err := store.append(&e); err != nil {
+			glog.Fatalf("write-ahead log: %v", err)
+		}
+	}
+	apply(e)
+}
+
+// apply makes the change of e to the tables. Queue entries change nothing,
+// pods are queued by the caller of commit.
+func apply(e walEntry) {
+	switch e.Op {
+	case opRegister:
+		registerCluster(*e.Cluster, e.Time)
+	case opUpdate:
+		if _, ok := clustersInfo[e.Cluster.Id]; ok {
+			updateCluster(*e.Cluster, e.Time)
+		}
+	case opExpire:
+		if cluster, ok := clustersInfo[e.ClusterId]; ok && cluster.Healthy {
+			markUnhealthy(cluster)
+		}
+	case opDeregister:
+		if cluster, ok := clustersInfo[e.ClusterId]; ok {
+			removeCluster(cluster)
+		}
+	case opCordon:
+		setCordoned(e.ClusterId, e.Cordoned)
+	case opPlace:
+		place(*e.Pod, e.Dest, e.Node, e.Time)
+	case opRelease:
+		releasePod(*e.Pod)
+	}
+}
+
+func (s *stateStore) append(e *walEntry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e.Seq = s.seq + 1
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.write(*e, line)
+}
+
+// write logs e, marshaled to line, and syncs it. It is called with lock
+// held.
+func (s *stateStore) write(e walEntry, line []byte) error {
+	if _, err := s.wal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	s.seq = e.Seq
+	s.track(e)
+	if len(s.recent) == replicationBacklog {
+		s.recent = append(s.recent[:0], s.recent[1:]...)
+	}
+	s.recent = append(s.recent, line)
+	close(s.appended)
+	s.appended = make(chan struct{})
+	return nil
+}
+
+// track follows the queues through e.
+func (s *stateStore) track(e walEntry) {
+	switch e.Op {
+	case opQueue:
+		s.queues[e.Pod.ClusterId] = append(s.queues[e.Pod.ClusterId], queuedPod{*e.Pod, e.Time})
+	case opPlace:
+		if !e.Queued {
+			return
+		}
+		queue := s.queues[e.Pod.ClusterId]
+		for i, queued := range queue {
+			if podKey(queued.Pod) == podKey(*e.Pod) {
+				s.queues[e.Pod.ClusterId] = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+	case opDeregister:
+		delete(s.queues, e.ClusterId)
+	}
+}
+
+// OpenState rebuilds the tables from the state directory dir and persists
+// every later change there. It must be called before the scheduler serves
+// any call, and Lead before it runs Schedule.
+func OpenState(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s := &stateStore{dir: dir, queues: make(map[string][]queuedPod), appended: make(chan struct{})}
+	if err := s.loadSnapshot(); err != nil {
+		return err
+	}
+	replayed, err := s.replay()
+	if err != nil {
+		return err
+	}
+	if s.wal, err = os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+		return err
+	}
+	for id := range clustersInfo {
+		computeClusterShare(id)
+	}
+	store = s
+	glog.Infof("Recovered %d clusters, %d placements and %d log entries from %s.", len(clustersInfo), len(placements), replayed, dir)
+	return s.snapshot()
+}
+
+// Lead fills clustersPodsQ with the pods the recovered or replicated tables
+// hold queued. It must be called before Schedule and DispatchPods run.
+func Lead() {
+	if store == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.restoreQueues()
+}
+
+func (s *stateStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.restore(data); err != nil {
+		return fmt.Errorf("%s: %v", snapshotFile, err)
+	}
+	return nil
+}
+
+// restore fills the empty tables from the snapshot data.
+func (s *stateStore) restore(data []byte) error {
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	s.seq = snap.Seq
+	for id, cluster := range snap.Clusters {
+		clustersInfo[id] = cluster
+		clustersShare[id] = 0
+		if cluster.Healthy {
+			TotalResource = TotalResource.Add(cluster.TotalResource)
+		}
+	}
+	for id, res := range snap.Allocated {
+		allocatedResource[id] = res
+	}
+	for id, res := range snap.Contributed {
+		contributedResource[id] = res
+	}
+	for key, placement := range snap.Placements {
+		placements[key] = placement
+	}
+	for name, node := range snap.IdleNodes {
+		IdleNodes[name] = node
+	}
+	for id := range snap.Cordoned {
+		cordoned[id] = true
+	}
+	for id, queue := range snap.Queues {
+		s.queues[id] = queue
+	}
+	return nil
+}
+
+// replay applies the entries logged after the snapshot. A torn or corrupt
+// entry ends the log, it is cut there so that later entries follow the last
+// good one.
+func (s *stateStore) replay() (int, error) {
+	path := filepath.Join(s.dir, walFile)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var good int64
+	replayed := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return replayed, nil
+		}
+		var e walEntry
+		if err != nil || json.Unmarshal(line, &e) != nil || e.Seq == 0 {
+			glog.Warningf("%s is cut at byte %d, the entries after it are lost.", path, good)
+			return replayed, os.Truncate(path, good)
+		}
+		good += int64(len(line))
+		if e.Seq <= s.seq {
+			// logged before the snapshot, which was taken before the log
+			// could be truncated.
+			continue
+		}
+		s.seq = e.Seq
+		s.track(e)
+		apply(e)
+		replayed++
+	}
+}
+
+// restoreQueues fills clustersPodsQ with the pods left in the queues and
+// puts their clusters in clustersPriorityQ.
+func (s *stateStore) restoreQueues() {
+	for id, queue := range s.queues {
+		if len(queue) == 0 {
+			delete(s.queues, id)
+			continue
+		}
+		podsQ := make(chan types.InterPod, len(queue)+20)
+		pendingLock.Lock()
+		for _, queued := range queue {
+			podsQ <- queued.Pod
+			pending[podKey(queued.Pod)] = pendingPod{queued.Pod, time.Unix(queued.QueuedAt, 0)}
+		}
+		pendingLock.Unlock()
+		clustersPodsQ[id] = podsQ
+		recordQueue(id, podsQ)
+		clustersPresent[id] = true
+		heap.Push(&clustersPriorityQ, &types.Cluster{Id: id, Priority: getClusterShare(id)})
+	}
+}
+
+// snapshot writes the tables to snapshotFile and truncates the log. It is
+// called with mu held.
+func (s *stateStore) snapshot() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, err := s.encode()
+	if err != nil {
+		return err
+	}
+	return s.persist(data)
+}
+
+// encode marshals the tables. It is called with mu and lock held.
+func (s *stateStore) encode() ([]byte, error) {
+	return json.Marshal(snapshot{
+		Seq:         s.seq,
+		Clusters:    clustersInfo,
+		Allocated:   allocatedResource,
+		Contributed: contributedResource,
+		Placements:  placements,
+		IdleNodes:   IdleNodes,
+		Cordoned:    cordoned,
+		Queues:      s.queues,
+	})
+}
+
+// persist writes the snapshot data and truncates the log. It is called with
+// lock held.
+func (s *stateStore) persist(data []byte) error {
+	path := filepath.Join(s.dir, snapshotFile)
+	if err := writeFileSync(path+".tmp", data); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+	// a crash before the truncation leaves entries the snapshot already
+	// holds, replay skips them by Seq.
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	return s.wal.Sync()
+}
+
+// SnapshotState snapshots the tables every interval, if they are persisted.
+func SnapshotState(interval time.Duration) {
+	if store == nil || interval <= 0 {
+		return
+	}
+	for range clk.Tick(interval) {
+		mu.Lock()
+		if err := store.snapshot(); err != nil {
+			glog.Errorf("snapshot: %v", err)
+		}
+		mu.Unlock()
+	}
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
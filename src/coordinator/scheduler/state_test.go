@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"types"
+)
+
+// crash drops the tables and the store as if the coordinator died.
+func crash(t *testing.T) {
+	t.Helper()
+	if err := store.wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store = nil
+	emptyTables()
+}
+
+func TestReplayAfterTornWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string
+	}{
+		{"torn entry", `{"seq":4,"op":"regis`},
+		{"corrupt entry", "not json\n"},
+		{"entry without seq", `{"op":"deregister","clusterId":"cluster1"}` + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearTables(t)
+			dir := t.TempDir()
+			if err := OpenState(dir); err != nil {
+				t.Fatal(err)
+			}
+			RegisterCluster(types.Cluster{Id: "cluster1", TotalResource: testResource(4000, 4096)})
+			RegisterCluster(types.Cluster{Id: "cluster2", TotalResource: testResource(4000, 4096)})
+			pod := testInterPod("cluster1", "p", 1000, 1024)
+			mu.Lock()
+			commit(walEntry{Op: opPlace, Pod: &pod, Dest: "cluster2"})
+			mu.Unlock()
+			crash(t)
+			path := filepath.Join(dir, walFile)
+			good, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wal, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wal.WriteString(test.tail)
+			wal.Close()
+
+			s := &stateStore{dir: dir, queues: make(map[string][]queuedPod), appended: make(chan struct{})}
+			if replayed, err := s.replay(); err != nil || replayed != 3 {
+				t.Fatalf("%d entries replayed, want 3: %v", replayed, err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Size() != good.Size() {
+				t.Fatalf("log not cut after the last good entry: %v", err)
+			}
+			emptyTables()
+			if err := OpenState(dir); err != nil {
+				t.Fatal(err)
+			}
+			if len(clustersInfo) != 2 {
+				t.Errorf("%d clusters recovered, want 2", len(clustersInfo))
+			}
+			if dest, ok := PlacementDest(pod); !ok || dest != "cluster2" {
+				t.Errorf("placement of p on %q recovered, want cluster2", dest)
+			}
+			if !allocatedResource["cluster1"].Equal(pod.Request()) {
+				t.Errorf("cluster1 allocated %v, want %v", allocatedResource["cluster1"], pod.Request())
+			}
+			// entries logged after the cut are recovered.
+			RegisterCluster(types.Cluster{Id: "cluster3", TotalResource: testResource(4000, 4096)})
+			crash(t)
+			if err := OpenState(dir); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := clustersInfo["cluster3"]; !ok || len(clustersInfo) != 3 {
+				t.Errorf("%d clusters recovered, want 3", len(clustersInfo))
+			}
+		})
+	}
+}
+
+func TestSnapshotTruncatesLog(t *testing.T) {
+	tests := []struct {
+		name string
+		// crashBeforeTruncate puts back the entries the snapshot holds, as
+		// a crash between writing it and truncating the log leaves them.
+		crashBeforeTruncate bool
+	}{
+		{"log truncated", false},
+		{"crash before the truncation", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearTables(t)
+			dir := t.TempDir()
+			if err := OpenState(dir); err != nil {
+				t.Fatal(err)
+			}
+			RegisterCluster(types.Cluster{Id: "cluster1", TotalResource: testResource(4000, 4096)})
+			RegisterCluster(types.Cluster{Id: "cluster2", TotalResource: testResource(4000, 4096)})
+			pod := testInterPod("cluster1", "p", 1000, 1024)
+			mu.Lock()
+			commit(walEntry{Op: opPlace, Pod: &pod, Dest: "cluster2"})
+			mu.Unlock()
+			path := filepath.Join(dir, walFile)
+			logged, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			err = store.snapshot()
+			mu.Unlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+				t.Fatalf("log not truncated by the snapshot: %v", err)
+			}
+			crash(t)
+			if test.crashBeforeTruncate {
+				if err := os.WriteFile(path, logged, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := OpenState(dir); err != nil {
+				t.Fatal(err)
+			}
+			if len(clustersInfo) != 2 {
+				t.Errorf("%d clusters recovered, want 2", len(clustersInfo))
+			}
+			if !allocatedResource["cluster1"].Equal(pod.Request()) {
+				t.Errorf("cluster1 allocated %v, want %v once", allocatedResource["cluster1"], pod.Request())
+			}
+			if !contributedResource["cluster2"].Equal(pod.Request()) {
+				t.Errorf("cluster2 contributed %v, want %v once", contributedResource["cluster2"], pod.Request())
+			}
+		})
+	}
+}
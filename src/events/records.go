@@ -0,0 +1,92 @@
+package events
+
+import "strconv"
+
+// Times in records are seconds since the scheduler that writes them started,
+// except the unix times of a pod's life in ScheduleRecord.
+
+// ScheduleRecord is written by the source cluster of a pod once it
+// finished, to scheduleData.
+type ScheduleRecord struct {
+	Time   int64  `json:"time"`
+	Tenant string `json:"tenant"`
+	Pod    string `json:"pod"`
+	// Submitted is when the pod was created on its source cluster, Created
+	// when on the cluster it runs on and Started when it began running.
+	Submitted int64 `json:"submitted"`
+	Created   int64 `json:"created"`
+	Started   int64 `json:"started"`
+	// Wait is from Submitted to Started, TotalWait the sum of Wait over the
+	// pods written so far.
+	Wait      int64 `json:"wait"`
+	TotalWait int64 `json:"totalWait"`
+}
+
+func (ScheduleRecord) Header() []string {
+	return []string{"time", "tenant", "pod", "submitted", "created", "started", "wait", "totalWait"}
+}
+
+func (r ScheduleRecord) Row() []string {
+	return []string{formatInt(r.Time), r.Tenant, r.Pod, formatInt(r.Submitted), formatInt(r.Created),
+		formatInt(r.Started), formatInt(r.Wait), formatInt(r.TotalWait)}
+}
+
+// UsageRecord is the fraction of a cluster's resources requested by running
+// pods, written to clusterData whenever a pod starts or finishes.
+type UsageRecord struct {
+	Time   int64   `json:"time"`
+	Cpu    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
+}
+
+func (UsageRecord) Header() []string {
+	return []string{"time", "cpu", "memory"}
+}
+
+func (r UsageRecord) Row() []string {
+	return []string{formatInt(r.Time), formatFloat(r.Cpu), formatFloat(r.Memory)}
+}
+
+// TenantShareRecord is a tenant's dominant share and allocation, written to
+// userData when one of its pods is placed.
+type TenantShareRecord struct {
+	Tenant   string  `json:"tenant"`
+	Time     int64   `json:"time"`
+	Share    float64 `json:"share"`
+	MilliCpu int64   `json:"milliCpu"`
+	Memory   int64   `json:"memory"`
+}
+
+func (TenantShareRecord) Header() []string {
+	return []string{"tenant", "time", "share", "milliCpu", "memory"}
+}
+
+func (r TenantShareRecord) Row() []string {
+	return []string{r.Tenant, formatInt(r.Time), formatFloat(r.Share), formatInt(r.MilliCpu), formatInt(r.Memory)}
+}
+
+// ClusterShareRecord is a cluster's dominant share and allocation, written
+// by the coordinator to federationData when one of its pods is placed.
+type ClusterShareRecord struct {
+	Cluster  string  `json:"cluster"`
+	Time     int64   `json:"time"`
+	Share    float64 `json:"share"`
+	MilliCpu int64   `json:"milliCpu"`
+	Memory   int64   `json:"memory"`
+}
+
+func (ClusterShareRecord) Header() []string {
+	return []string{"cluster", "time", "share", "milliCpu", "memory"}
+}
+
+func (r ClusterShareRecord) Row() []string {
+	return []string{r.Cluster, formatInt(r.Time), formatFloat(r.Share), formatInt(r.MilliCpu), formatInt(r.Memory)}
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
@@ -0,0 +1,271 @@
+// Package events writes the records the schedulers keep for offline
+// analysis, one file per kind of record, as CSV with a header or as JSON
+// lines. Files are rotated by size and age and synced to disk as configured.
+package events
+
+import (
+	"bytes"
+	"clock"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// rotatedLayout is the time suffix of rotated files, it sorts by time.
+const rotatedLayout = "20060102T150405.000"
+
+var ErrClosed = errors.New("events: sink is closed")
+
+// Record is one row of a file. Header and Row give its CSV columns, JSON
+// lines are the record marshaled with its json tags.
+type Record interface {
+	Header() []string
+	Row() []string
+}
+
+type Config struct {
+	// Dir is where the files are written, it is created if missing.
+	Dir string
+	// Format is FormatCSV or FormatJSONL.
+	Format string
+	// MaxSize rotates a file before it grows past this many bytes, MaxAge
+	// once it has been written to for this long. Zero disables either.
+	MaxSize int64
+	MaxAge  time.Duration
+	// MaxBackups is how many rotated files of a kind are kept, zero keeps
+	// them all.
+	MaxBackups int
+	// SyncInterval is how often written records are synced to disk: zero
+	// syncs every record, a negative interval leaves it to the OS. Syncs
+	// are checked on writes, rotation and Close.
+	SyncInterval time.Duration
+	// Clock dates rotations, the wall clock if nil.
+	Clock clock.Clock
+}
+
+// ParseSync reads a sync policy: "always", "none", or the interval between
+// syncs, such as "5s".
+func ParseSync(s string) (time.Duration, error) {
+	switch s {
+	case "always":
+		return 0, nil
+	case "none":
+		return -1, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid sync policy %q, want always, none or an interval", s)
+	}
+	return d, nil
+}
+
+// Validate checks c without opening anything.
+func (c Config) Validate() error {
+	if c.Dir == "" {
+		return fmt.Errorf("events: directory is empty")
+	}
+	if c.Format != FormatCSV && c.Format != FormatJSONL {
+		return fmt.Errorf("events: invalid format %q, want %s or %s", c.Format, FormatCSV, FormatJSONL)
+	}
+	if c.MaxSize < 0 || c.MaxAge < 0 || c.MaxBackups < 0 {
+		return fmt.Errorf("events: rotation limits must not be negative")
+	}
+	return nil
+}
+
+// Sink appends records of one kind to <Dir>/<name>.<Format>. It is safe for
+// concurrent use.
+type Sink struct {
+	config Config
+	path   string
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	opened   time.Time
+	lastSync time.Time
+	dirty    bool
+}
+
+// Open opens the file of name for appending, records already in it are
+// kept.
+func Open(name string, config Config) (*Sink, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real{}
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		config: config,
+		path:   filepath.Join(config.Dir, name+"."+config.Format),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	s.opened = s.config.Clock.Now()
+	s.lastSync = s.opened
+	return nil
+}
+
+// Write appends r, rotating the file first if r would take it past its
+// limits.
+func (s *Sink) Write(r Record) error {
+	line, err := s.encode(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return ErrClosed
+	}
+	now := s.config.Clock.Now()
+	if s.size > 0 && s.due(now, int64(len(line))) {
+		if err := s.rotate(now); err != nil {
+			return fmt.Errorf("events: rotate %s: %v", s.path, err)
+		}
+	}
+	if s.size == 0 && s.config.Format == FormatCSV {
+		header, err := encodeCSV(r.Header())
+		if err != nil {
+			return err
+		}
+		line = append(header, line...)
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("events: write %s: %v", s.path, err)
+	}
+	s.dirty = true
+	if s.config.SyncInterval >= 0 && now.Sub(s.lastSync) >= s.config.SyncInterval {
+		return s.sync(now)
+	}
+	return nil
+}
+
+func (s *Sink) encode(r Record) ([]byte, error) {
+	if s.config.Format == FormatJSONL {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		return append(line, '\n'), nil
+	}
+	return encodeCSV(r.Row())
+}
+
+func encodeCSV(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(fields)
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// due tells whether writing n more bytes needs a new file.
+func (s *Sink) due(now time.Time, n int64) bool {
+	if s.config.MaxSize > 0 && s.size+n > s.config.MaxSize {
+		return true
+	}
+	return s.config.MaxAge > 0 && now.Sub(s.opened) >= s.config.MaxAge
+}
+
+// rotate renames the current file after the time it is rotated at, opens a
+// fresh one and drops the rotated files beyond MaxBackups.
+func (s *Sink) rotate(now time.Time) error {
+	if err := s.sync(now); err != nil {
+		return err
+	}
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	ext := filepath.Ext(s.path)
+	rotated := strings.TrimSuffix(s.path, ext) + "-" + now.UTC().Format(rotatedLayout) + ext
+	renameErr := os.Rename(s.path, rotated)
+	// Keep appending to the old file if it could not be renamed.
+	if err := s.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	return s.prune()
+}
+
+func (s *Sink) prune() error {
+	if s.config.MaxBackups == 0 {
+		return nil
+	}
+	ext := filepath.Ext(s.path)
+	rotated, err := filepath.Glob(strings.TrimSuffix(s.path, ext) + "-*" + ext)
+	if err != nil {
+		return err
+	}
+	sort.Strings(rotated)
+	for len(rotated) > s.config.MaxBackups {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+func (s *Sink) sync(now time.Time) error {
+	s.lastSync = now
+	if !s.dirty || s.config.SyncInterval < 0 {
+		return nil
+	}
+	s.dirty = false
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("events: sync %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// Close syncs and closes the file, later writes fail with ErrClosed.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return ErrClosed
+	}
+	err := s.sync(s.config.Clock.Now())
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
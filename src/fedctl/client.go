@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"types"
+	"types/federationpb"
+)
+
+// getJSON decodes the admin API resource at path into v.
+func getJSON(path string, v interface{}) error {
+	client := http.Client{Timeout: *rpcTimeout}
+	resp, err := client.Get(strings.TrimRight(*adminURL, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// findCluster looks a registered cluster up by id.
+func findCluster(id string) (types.ClusterStatus, error) {
+	var clusters []types.ClusterStatus
+	if err := getJSON("/api/v1/clusters", &clusters); err != nil {
+		return types.ClusterStatus{}, err
+	}
+	for _, c := range clusters {
+		if c.Id == id {
+			return c, nil
+		}
+	}
+	return types.ClusterStatus{}, fmt.Errorf("cluster %s is not registered", id)
+}
+
+func callContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), *rpcTimeout)
+}
+
+func cordonCluster(id string, cordoned bool) error {
+	conn, err := federationpb.Dial(*serverAddress, federationpb.WithServerName(tlsConfig, *coordinatorId))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := callContext()
+	defer cancel()
+	_, err = federationpb.NewCoordinatorClient(conn).CordonCluster(ctx, &federationpb.CordonClusterRequest{ClusterId: id, Cordoned: cordoned})
+	return err
+}
+
+// tenantShares asks a member for its tenants, in the protocol it registered
+// with.
+func tenantShares(cluster types.ClusterStatus) ([]types.TenantStatus, error) {
+	addr := net.JoinHostPort(cluster.Ip, cluster.Port)
+	config := federationpb.WithServerName(tlsConfig, cluster.Id)
+	if cluster.Version == 0 {
+		client, err := federationpb.DialRPC(addr, config)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		var tenants []types.TenantStatus
+		err = client.Call("Server.TenantShares", new(int), &tenants)
+		return tenants, err
+	}
+	conn, err := federationpb.Dial(addr, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ctx, cancel := callContext()
+	defer cancel()
+	resp, err := federationpb.NewMemberClient(conn).TenantShares(ctx, &federationpb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return federationpb.DecodeTenantShares(resp), nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"types"
+)
+
+// drainPoll is how often drain checks what a cluster still runs for others.
+const drainPoll = 5 * time.Second
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+func clusters(args []string) error {
+	var list []types.ClusterStatus
+	if err := getJSON("/api/v1/clusters", &list); err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintln(w, "CLUSTER\tSTATUS\tADDRESS\tPROTOCOL\tNODES\tTOTAL\tSHARE\tPENDING\tLAST HEARTBEAT")
+	for _, c := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s:%s\t%d\t%d\t%s\t%.4f\t%d\t%s\n", c.Id, clusterState(c), c.Ip, c.Port, c.Version,
+			c.Nodes, formatQuantities(c.TotalResource), c.Share, c.PendingPods, since(c.LastHeartbeat))
+	}
+	return w.Flush()
+}
+
+func clusterState(c types.ClusterStatus) string {
+	state := "Healthy"
+	if !c.Healthy {
+		state = "Unhealthy"
+	}
+	if c.Cordoned {
+		state += ",Cordoned"
+	}
+	return state
+}
+
+func shares(args []string) error {
+	var list []types.ClusterStatus
+	if err := getJSON("/api/v1/clusters", &list); err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintln(w, "CLUSTER\tSHARE\tALLOCATED\tCONTRIBUTED")
+	for _, c := range list {
+		fmt.Fprintf(w, "%s\t%.4f\t%s\t%s\n", c.Id, c.Share, formatQuantities(c.Allocated), formatQuantities(c.Contributed))
+	}
+	return w.Flush()
+}
+
+func tenants(args []string) error {
+	id, err := oneArg(args, "cluster")
+	if err != nil {
+		return err
+	}
+	cluster, err := findCluster(id)
+	if err != nil {
+		return err
+	}
+	list, err := tenantShares(cluster)
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintln(w, "TENANT\tSHARE\tWEIGHT\tALLOCATED\tPENDING")
+	for _, t := range list {
+		fmt.Fprintf(w, "%s\t%.4f\t%g\t%s\t%d\n", t.Tenant, t.Share, t.Weight, formatQuantities(t.Allocated), t.PendingPods)
+	}
+	return w.Flush()
+}
+
+func pending(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("want at most one cluster, got %q", strings.Join(args, " "))
+	}
+	var list []types.PendingPod
+	if err := getJSON("/api/v1/pending", &list); err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintln(w, "CLUSTER\tTENANT\tPOD\tREQUEST\tWAITING")
+	for _, p := range list {
+		if len(args) == 1 && p.ClusterId != args[0] {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.ClusterId, p.Tenant, p.Name, formatQuantities(p.Request), since(p.QueuedAt))
+	}
+	return w.Flush()
+}
+
+// where reports the placements of a pod among the coordinator's recent
+// decisions, and whether it is still waiting for one.
+func where(args []string) error {
+	fs := flag.NewFlagSet("where", flag.ExitOnError)
+	source := fs.String("source", "", "only pods of this source cluster")
+	fs.Parse(args)
+	pod, err := oneArg(fs.Args(), "TENANT/POD")
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(pod, "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("want TENANT/POD, got %q", pod)
+	}
+	tenant, name := parts[0], parts[1]
+
+	found := false
+	var waiting []types.PendingPod
+	if err := getJSON("/api/v1/pending", &waiting); err != nil {
+		return err
+	}
+	for _, p := range waiting {
+		if p.Tenant == tenant && p.Name == name && (*source == "" || p.ClusterId == *source) {
+			fmt.Printf("%s of %s is waiting for a placement since %s.\n", pod, p.ClusterId, since(p.QueuedAt))
+			found = true
+		}
+	}
+	var placements []types.PlacementRecord
+	if err := getJSON("/api/v1/placements", &placements); err != nil {
+		return err
+	}
+	for _, p := range placements {
+		if p.Tenant != tenant || p.Name != name || (*source != "" && p.Source != *source) {
+			continue
+		}
+		dest := p.Dest
+		if p.Node != "" {
+			dest += " node " + p.Node
+		}
+		fmt.Printf("%s of %s was placed on %s %s: %s.\n", pod, p.Source, dest, since(p.Time), p.Reason)
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("%s is neither pending nor among the last %d placements", pod, len(placements))
+	}
+	return nil
+}
+
+func cordon(args []string, cordoned bool) error {
+	id, err := oneArg(args, "cluster")
+	if err != nil {
+		return err
+	}
+	if err := cordonCluster(id, cordoned); err != nil {
+		return err
+	}
+	if cordoned {
+		fmt.Printf("cluster %s cordoned\n", id)
+	} else {
+		fmt.Printf("cluster %s uncordoned\n", id)
+	}
+	return nil
+}
+
+// drain cordons a cluster and waits until it no longer contributes to pods
+// of other clusters. Those pods run to completion, none is evicted.
+func drain(args []string) error {
+	fs := flag.NewFlagSet("drain", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 30*time.Minute, "give up after this long")
+	fs.Parse(args)
+	id, err := oneArg(fs.Args(), "cluster")
+	if err != nil {
+		return err
+	}
+	if err := cordon([]string{id}, true); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(*timeout)
+	for {
+		var ledgers map[string]types.Ledger
+		if err := getJSON("/api/v1/ledgers", &ledgers); err != nil {
+			return err
+		}
+		ledger, ok := ledgers[id]
+		if !ok {
+			return fmt.Errorf("cluster %s is no longer registered", id)
+		}
+		if isZero(ledger.Contributed) {
+			fmt.Printf("cluster %s drained\n", id)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cluster %s still runs %s for other clusters after %v", id, formatQuantities(ledger.Contributed), *timeout)
+		}
+		fmt.Printf("waiting for %s of other clusters on %s\n", formatQuantities(ledger.Contributed), id)
+		time.Sleep(drainPoll)
+	}
+}
+
+func isZero(q types.Quantities) bool {
+	for _, v := range q {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// formatQuantities prints cpu in millicores, memory in MiB and everything
+// else as is, in name order.
+func formatQuantities(q types.Quantities) string {
+	if isZero(q) {
+		return "-"
+	}
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		switch v := q[name]; name {
+		case types.ResourceCpu:
+			parts = append(parts, fmt.Sprintf("%s=%dm", name, v))
+		case types.ResourceMemory:
+			parts = append(parts, fmt.Sprintf("%s=%dMi", name, v/(1024*1024)))
+		default:
+			parts = append(parts, fmt.Sprintf("%s=%d", name, v))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// since prints how long ago a unix time was.
+func since(unix int64) string {
+	if unix == 0 {
+		return "never"
+	}
+	return time.Since(time.Unix(unix, 0)).Round(time.Second).String() + " ago"
+}
@@ -0,0 +1,90 @@
+// Command fedctl inspects and operates a federation. It reads the
+// coordinator's admin API and calls the RPC servers of the coordinator and
+// the members, with the operator certificate if the federation uses TLS:
+//
+//	fedctl clusters                      registered clusters
+//	fedctl shares                        dominant share and ledgers by cluster
+//	fedctl tenants CLUSTER               tenant shares of a member
+//	fedctl pending [CLUSTER]             uploaded pods waiting for a placement
+//	fedctl where [-source CLUSTER] TENANT/POD
+//	                                     where a pod was placed and why
+//	fedctl cordon CLUSTER                keep pods of other clusters off CLUSTER
+//	fedctl uncordon CLUSTER
+//	fedctl drain [-timeout 30m] CLUSTER  cordon and wait for the pods of other
+//	                                     clusters to finish
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"types/federationpb"
+)
+
+var (
+	adminURL      = flag.String("admin", "http://localhost:9235", "coordinator admin API")
+	serverAddress = flag.String("server", "localhost:1234", "coordinator RPC address")
+	tlsCert       = flag.String("tls_cert", "", "operator certificate, issued to \""+federationpb.OperatorIdentity+"\"")
+	tlsKey        = flag.String("tls_key", "", "key of -tls_cert")
+	tlsCA         = flag.String("tls_ca", "", "CA that issues the certificates of the federation")
+	coordinatorId = flag.String("coordinator_identity", federationpb.CoordinatorIdentity, "identity of the coordinator's certificate")
+	rpcTimeout    = flag.Duration("rpc_timeout", 10*time.Second, "deadline of RPC and admin API calls")
+
+	tlsConfig *tls.Config
+)
+
+// commands maps a subcommand to its function, which gets the arguments
+// after the subcommand name.
+var commands = map[string]func(args []string) error{
+	"clusters": clusters,
+	"shares":   shares,
+	"tenants":  tenants,
+	"pending":  pending,
+	"where":    where,
+	"cordon":   func(args []string) error { return cordon(args, true) },
+	"uncordon": func(args []string) error { return cordon(args, false) },
+	"drain":    drain,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fedctl [flags] clusters|shares|tenants|pending|where|cordon|uncordon|drain [args]")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fedctl: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	if tlsConfig, err = federationpb.LoadTLS(*tlsCert, *tlsKey, *tlsCA); err != nil {
+		fatal(err)
+	}
+	if err := command(flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "fedctl:", err)
+	os.Exit(1)
+}
+
+// oneArg checks that a command got exactly one argument, named name.
+func oneArg(args []string, name string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("want one %s, got %q", name, strings.Join(args, " "))
+	}
+	return args[0], nil
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"flag"
+	"os"
+	"os/signal"
 	"scheduler"
+	"syscall"
 
 	"github.com/golang/glog"
 )
@@ -14,10 +17,27 @@ func main() {
 	defer glog.Flush()
 
 	glog.Info("scheduler starts.")
-	scheduler.Init()
-	scheduler.RpcInit()
-	go scheduler.DispatchPods()
-	go scheduler.Schedule()
-	go scheduler.HandleData()
-	scheduler.WatchPods()
+	m := scheduler.Init()
+	m.RpcInit()
+	go m.UpdateAllocatedResource()
+	go m.DispatchPods()
+	go m.Schedule()
+	go m.HandleData()
+	go m.KeepAlive()
+	go m.ServeMetrics()
+	go m.WatchNamespaces()
+	go m.WatchNodes()
+	go shutdown(m)
+	m.WatchPods()
+}
+
+// shutdown leaves the federation cleanly on SIGINT/SIGTERM.
+func shutdown(m *scheduler.Member) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	glog.Info("scheduler stops.")
+	m.DeregisterCluster()
+	glog.Flush()
+	os.Exit(0)
 }
@@ -0,0 +1,321 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"events"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"types/federationpb"
+)
+
+const (
+	defaultClientPort = "4321"
+)
+
+// Config is the identity and endpoints of this member. Values are read from
+// the file given by -config, then overridden by FEDERATION_* environment
+// variables, then by command line flags.
+type Config struct {
+	ClusterId     string `json:"clusterId"`
+	ServerAddress string `json:"serverAddress"` // coordinator host
+	ServerPort    string `json:"serverPort"`    // coordinator port
+	// Coordinators are the host:port of every coordinator replica, calls go
+	// to whichever leads. It replaces ServerAddress and ServerPort if set.
+	Coordinators []string `json:"coordinators"`
+	// ClientAddress and ClientPort are advertised to the coordinator and to
+	// other members, ListenAddress is what the RPC server binds to. They
+	// differ for members behind NAT.
+	ClientAddress string `json:"clientAddress"`
+	ClientPort    string `json:"clientPort"`
+	ListenAddress string `json:"listenAddress"`
+	// Local keeps pods that do not fit here instead of outsourcing them,
+	// for tenants without their own federation-scheduler/local. Pods are
+	// kept here anyway while the coordinator cannot be reached.
+	Local bool `json:"local"`
+	// Weight is registered as the cluster's weight, used by the
+	// coordinator's weighted-drf fairness policy.
+	Weight float64 `json:"weight"`
+	// ExcludedNamespaces are never treated as tenants.
+	ExcludedNamespaces []string `json:"excludedNamespaces"`
+	// StripFields are the pod spec fields rewritten for safety when running
+	// pods of other clusters, see stripRules.
+	StripFields []string `json:"stripFields"`
+	// Scoring is the node scoring strategy, SchedulerScoring overrides it
+	// for pods of the given schedulerName.
+	Scoring          string            `json:"scoring"`
+	SchedulerScoring map[string]string `json:"schedulerScoring"`
+	// Protocol is how the coordinator is called: "grpc", or "netrpc" for
+	// coordinators that predate it. RpcTimeout is the deadline of gRPC
+	// calls, e.g. "10s". HeartbeatInterval is how often the coordinator
+	// hears from this cluster when nothing changes, it must stay well
+	// below the coordinator's cluster_timeout.
+	Protocol          string `json:"protocol"`
+	RpcTimeout        string `json:"rpcTimeout"`
+	HeartbeatInterval string `json:"heartbeatInterval"`
+	// TLSCert, TLSKey and TLSCA enable mutual TLS on every call. The
+	// certificate must be issued to ClusterId, CoordinatorIdentity is what
+	// the coordinator's certificate is issued to.
+	TLSCert             string `json:"tlsCert"`
+	TLSKey              string `json:"tlsKey"`
+	TLSCA               string `json:"tlsCA"`
+	CoordinatorIdentity string `json:"coordinatorIdentity"`
+	// OperatorIdentity is what the certificate of fedctl is issued to.
+	OperatorIdentity string `json:"operatorIdentity"`
+	// MetricsAddress serves /metrics for Prometheus, "none" disables it.
+	MetricsAddress string `json:"metricsAddress"`
+	// Events* is where and how scheduleData, clusterData and userData are
+	// written, see events.Config. EventsFormat is "csv" or "jsonl",
+	// EventsMaxSize is in bytes, EventsMaxAge a duration such as "24h" and
+	// EventsSync "always", "none" or the interval between syncs.
+	EventsDir        string `json:"eventsDir"`
+	EventsFormat     string `json:"eventsFormat"`
+	EventsMaxSize    int64  `json:"eventsMaxSize"`
+	EventsMaxAge     string `json:"eventsMaxAge"`
+	EventsMaxBackups int    `json:"eventsMaxBackups"`
+	EventsSync       string `json:"eventsSync"`
+}
+
+var (
+	configFile        = flag.String("config", "", "path to the member config file (JSON)")
+	clusterIdFlag     = flag.String("cluster_id", "", "id of this cluster in the federation")
+	serverAddressFlag = flag.String("server_address", "", "coordinator address")
+	serverPortFlag    = flag.String("server_port", "", "coordinator port")
+	coordinatorsFlag  = flag.String("coordinators", "", "comma separated host:port of the coordinator replicas, instead of -server_address and -server_port")
+	clientAddressFlag = flag.String("advertise_address", "", "address advertised to the federation")
+	clientPortFlag    = flag.String("advertise_port", "", "port advertised to the federation")
+	listenAddressFlag = flag.String("listen_address", "", "address the member RPC server listens on, default :<advertise_port>")
+	localFlag         = flag.String("local", "", "true to never outsource pods of tenants without their own federation-scheduler/local")
+	weightFlag        = flag.String("weight", "", "weight of this cluster under weighted fairness policies")
+	scoringFlag       = flag.String("scoring", "", "node scoring strategy: "+strings.Join(ScoringStrategies(), ", "))
+	schedScoringFlag  = flag.String("scheduler_scoring", "", "comma separated schedulerName=strategy overrides of -scoring")
+	stripFlag         = flag.String("strip_fields", "", "comma separated pod spec fields stripped from outsourced pods, \"none\" for none")
+	protocolFlag      = flag.String("protocol", "", "protocol to the coordinator: grpc or netrpc")
+	rpcTimeoutFlag    = flag.String("rpc_timeout", "", "deadline of gRPC calls, default 10s")
+	heartbeatFlag     = flag.String("heartbeat_interval", "", "how often heartbeats are sent to the coordinator, default 10s")
+	tlsCertFlag       = flag.String("tls_cert", "", "certificate of this cluster, issued to its cluster id")
+	tlsKeyFlag        = flag.String("tls_key", "", "key of -tls_cert")
+	tlsCAFlag         = flag.String("tls_ca", "", "CA that issues the certificates of the federation")
+	metricsFlag       = flag.String("metrics_address", "", "address /metrics is served on for Prometheus, default :9321, \"none\" disables it")
+	operatorFlag      = flag.String("operator_identity", "", "identity of the certificate operators call with, default "+federationpb.OperatorIdentity)
+	coordIdentityFlag = flag.String("coordinator_identity", "", "identity of the coordinator's certificate, default "+federationpb.CoordinatorIdentity)
+	eventsDirFlag     = flag.String("events_dir", "", "directory scheduleData, clusterData and userData are written to, default ..")
+	eventsFormatFlag  = flag.String("events_format", "", "format of the event files: csv or jsonl, default csv")
+	eventsSizeFlag    = flag.String("events_max_size", "", "rotate event files before they grow past this many bytes, default 0 (never)")
+	eventsAgeFlag     = flag.String("events_max_age", "", "rotate event files after this long, e.g. 24h, default never")
+	eventsBackupsFlag = flag.String("events_max_backups", "", "rotated event files kept per kind, default 0 (all)")
+	eventsSyncFlag    = flag.String("events_sync", "", "fsync of event files: always, none or an interval such as 5s, default none")
+	excludedFlag      = flag.String("excluded_namespaces", "", "comma separated namespaces that are not tenants, default default,kube-public,kube-system")
+)
+
+// DefaultConfig is the configuration of a member without config file,
+// environment or flags.
+func DefaultConfig() Config {
+	return Config{
+		ClusterId:     "cluster1",
+		ServerAddress: "localhost",
+		ServerPort:    "1234",
+		ClientAddress: "localhost",
+		ClientPort:    defaultClientPort,
+		Local:         true,
+		Weight:        1,
+		Scoring:       "first-fit",
+		Protocol:      "grpc",
+		RpcTimeout:    "10s",
+
+		HeartbeatInterval:   "10s",
+		CoordinatorIdentity: federationpb.CoordinatorIdentity,
+		OperatorIdentity:    federationpb.OperatorIdentity,
+		MetricsAddress:      ":9321",
+		EventsDir:           "..",
+		EventsFormat:        events.FormatCSV,
+		EventsSync:          "none",
+		ExcludedNamespaces:  []string{"default", "kube-public", "kube-system"},
+		StripFields:         stripFieldNames(),
+	}
+}
+
+// LoadConfig reads the file given by -config over DefaultConfig and applies
+// the environment and flags. The result is checked by NewMember.
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("parse %s: %v", *configFile, err)
+		}
+	}
+	override := func(dst *string, env string, flagValue *string) {
+		if v := os.Getenv(env); v != "" {
+			*dst = v
+		}
+		if *flagValue != "" {
+			*dst = *flagValue
+		}
+	}
+	override(&config.ClusterId, "FEDERATION_CLUSTER_ID", clusterIdFlag)
+	override(&config.ServerAddress, "FEDERATION_SERVER_ADDRESS", serverAddressFlag)
+	override(&config.ServerPort, "FEDERATION_SERVER_PORT", serverPortFlag)
+	coordinatorsValue := strings.Join(config.Coordinators, ",")
+	override(&coordinatorsValue, "FEDERATION_COORDINATORS", coordinatorsFlag)
+	config.Coordinators = nil
+	for _, addr := range strings.Split(coordinatorsValue, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			config.Coordinators = append(config.Coordinators, addr)
+		}
+	}
+	override(&config.ClientAddress, "FEDERATION_ADVERTISE_ADDRESS", clientAddressFlag)
+	override(&config.ClientPort, "FEDERATION_ADVERTISE_PORT", clientPortFlag)
+	override(&config.ListenAddress, "FEDERATION_LISTEN_ADDRESS", listenAddressFlag)
+	localValue := strconv.FormatBool(config.Local)
+	override(&localValue, "FEDERATION_LOCAL", localFlag)
+	var err error
+	if config.Local, err = strconv.ParseBool(localValue); err != nil {
+		return config, fmt.Errorf("invalid local %q", localValue)
+	}
+	weightValue := strconv.FormatFloat(config.Weight, 'f', -1, 64)
+	override(&weightValue, "FEDERATION_WEIGHT", weightFlag)
+	if config.Weight, err = strconv.ParseFloat(weightValue, 64); err != nil {
+		return config, fmt.Errorf("invalid weight %q", weightValue)
+	}
+	excludedValue := strings.Join(config.ExcludedNamespaces, ",")
+	override(&excludedValue, "FEDERATION_EXCLUDED_NAMESPACES", excludedFlag)
+	config.ExcludedNamespaces = strings.Split(excludedValue, ",")
+	stripValue := strings.Join(config.StripFields, ",")
+	override(&stripValue, "FEDERATION_STRIP_FIELDS", stripFlag)
+	if stripValue == "none" {
+		stripValue = ""
+	}
+	config.StripFields = strings.Split(stripValue, ",")
+	override(&config.Scoring, "FEDERATION_SCORING", scoringFlag)
+	schedScoringValue := ""
+	override(&schedScoringValue, "FEDERATION_SCHEDULER_SCORING", schedScoringFlag)
+	if schedScoringValue != "" {
+		config.SchedulerScoring = make(map[string]string)
+		for _, pair := range strings.Split(schedScoringValue, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return config, fmt.Errorf("invalid scheduler scoring %q", pair)
+			}
+			config.SchedulerScoring[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	override(&config.Protocol, "FEDERATION_PROTOCOL", protocolFlag)
+	override(&config.RpcTimeout, "FEDERATION_RPC_TIMEOUT", rpcTimeoutFlag)
+	override(&config.HeartbeatInterval, "FEDERATION_HEARTBEAT_INTERVAL", heartbeatFlag)
+	override(&config.TLSCert, "FEDERATION_TLS_CERT", tlsCertFlag)
+	override(&config.TLSKey, "FEDERATION_TLS_KEY", tlsKeyFlag)
+	override(&config.TLSCA, "FEDERATION_TLS_CA", tlsCAFlag)
+	override(&config.CoordinatorIdentity, "FEDERATION_COORDINATOR_IDENTITY", coordIdentityFlag)
+	override(&config.OperatorIdentity, "FEDERATION_OPERATOR_IDENTITY", operatorFlag)
+	override(&config.MetricsAddress, "FEDERATION_METRICS_ADDRESS", metricsFlag)
+	if config.MetricsAddress == "none" {
+		config.MetricsAddress = ""
+	}
+	override(&config.EventsDir, "FEDERATION_EVENTS_DIR", eventsDirFlag)
+	override(&config.EventsFormat, "FEDERATION_EVENTS_FORMAT", eventsFormatFlag)
+	override(&config.EventsMaxAge, "FEDERATION_EVENTS_MAX_AGE", eventsAgeFlag)
+	override(&config.EventsSync, "FEDERATION_EVENTS_SYNC", eventsSyncFlag)
+	sizeValue := strconv.FormatInt(config.EventsMaxSize, 10)
+	override(&sizeValue, "FEDERATION_EVENTS_MAX_SIZE", eventsSizeFlag)
+	if config.EventsMaxSize, err = strconv.ParseInt(sizeValue, 10, 64); err != nil {
+		return config, fmt.Errorf("invalid events max size %q", sizeValue)
+	}
+	backupsValue := strconv.Itoa(config.EventsMaxBackups)
+	override(&backupsValue, "FEDERATION_EVENTS_MAX_BACKUPS", eventsBackupsFlag)
+	if config.EventsMaxBackups, err = strconv.Atoi(backupsValue); err != nil {
+		return config, fmt.Errorf("invalid events max backups %q", backupsValue)
+	}
+	return config, nil
+}
+
+func (c Config) validate() error {
+	if c.ClusterId == "" || strings.ContainsAny(c.ClusterId, "/ ") {
+		return fmt.Errorf("invalid cluster id %q", c.ClusterId)
+	}
+	if c.Weight <= 0 {
+		return fmt.Errorf("invalid weight %v", c.Weight)
+	}
+	if c.Protocol != "grpc" && c.Protocol != "netrpc" {
+		return fmt.Errorf("invalid protocol %q, want grpc or netrpc", c.Protocol)
+	}
+	if c.TLSCert != "" && c.CoordinatorIdentity == "" {
+		return fmt.Errorf("coordinator identity is empty")
+	}
+	for _, addr := range c.Coordinators {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid coordinator %q: %v", addr, err)
+		}
+		if err := validatePort(port); err != nil {
+			return err
+		}
+	}
+	if c.ServerAddress == "" && len(c.Coordinators) == 0 {
+		return fmt.Errorf("server address is empty")
+	}
+	if c.ClientAddress == "" {
+		return fmt.Errorf("advertise address is empty")
+	}
+	if ip := net.ParseIP(c.ClientAddress); ip != nil && ip.IsUnspecified() {
+		return fmt.Errorf("advertise address %s is not reachable by other clusters", c.ClientAddress)
+	}
+	for _, port := range []string{c.ServerPort, c.ClientPort} {
+		if err := validatePort(port); err != nil {
+			return err
+		}
+	}
+	_, port, err := net.SplitHostPort(c.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %v", c.ListenAddress, err)
+	}
+	return validatePort(port)
+}
+
+// sinkConfig checks the Events* fields, the files are only opened by
+// HandleData.
+func (c Config) sinkConfig() (events.Config, error) {
+	sink := events.Config{
+		Dir:        c.EventsDir,
+		Format:     c.EventsFormat,
+		MaxSize:    c.EventsMaxSize,
+		MaxBackups: c.EventsMaxBackups,
+	}
+	if c.EventsMaxAge != "" {
+		age, err := time.ParseDuration(c.EventsMaxAge)
+		if err != nil || age < 0 {
+			return sink, fmt.Errorf("invalid events max age %q", c.EventsMaxAge)
+		}
+		sink.MaxAge = age
+	}
+	var err error
+	if sink.SyncInterval, err = events.ParseSync(c.EventsSync); err != nil {
+		return sink, err
+	}
+	return sink, sink.Validate()
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// clusterAddr joins a member's advertised ip and port, defaulting the port
+// for members that do not send one.
+func clusterAddr(ip, port string) string {
+	if port == "" {
+		port = defaultClientPort
+	}
+	return net.JoinHostPort(ip, port)
+}
@@ -0,0 +1,293 @@
+package scheduler
+
+import (
+	"clock"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+	"types"
+	"types/federationpb"
+
+	"github.com/golang/glog"
+)
+
+// reconnectMin and reconnectMax bound the backoff between attempts to reach
+// the coordinator again.
+const (
+	reconnectMin = time.Second
+	reconnectMax = time.Minute
+)
+
+// errDisconnected fails calls made while the coordinator cannot be reached.
+var errDisconnected = errors.New("not connected to the coordinator")
+
+// failoverConn calls the leader among the coordinator replicas at addrs.
+// A replica that does not lead answers with the address of the leader, the
+// call is then made again there. If the one called cannot be reached, the
+// others are tried in turn.
+//
+// register is called on every new connection before anything else, and
+// again if the coordinator forgot the cluster. Once no replica can be
+// reached the connection is down: calls fail at once with errDisconnected
+// while reconnect tries again with backoff, and connected is called when it
+// succeeds.
+type failoverConn struct {
+	addrs     []string
+	dial      func(addr string) (CoordinatorConn, error)
+	register  func(conn CoordinatorConn) error
+	connected func()
+	clk       clock.Clock
+
+	lock       sync.Mutex
+	addr       string
+	conn       CoordinatorConn
+	registered bool
+	down       bool
+}
+
+func newFailoverConn(addrs []string, dial func(addr string) (CoordinatorConn, error),
+	register func(conn CoordinatorConn) error, connected func(), clk clock.Clock) *failoverConn {
+	return &failoverConn{addrs: addrs, dial: dial, register: register, connected: connected, clk: clk}
+}
+
+// Connect makes the first connection, reconnecting in the background if it
+// fails.
+func (c *failoverConn) Connect() error {
+	err := c.try(func(CoordinatorConn) error { return nil })
+	if err != nil {
+		c.disconnect(err)
+		return err
+	}
+	c.up()
+	return nil
+}
+
+// Connected tells whether calls are made, as opposed to failing with
+// errDisconnected.
+func (c *failoverConn) Connected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return !c.down
+}
+
+// up records that the coordinator is reached and calls connected.
+func (c *failoverConn) up() {
+	c.lock.Lock()
+	c.down = false
+	addr := c.addr
+	c.lock.Unlock()
+	glog.Infof("Connected to coordinator %s.", addr)
+	coordinatorConnectedGauge.Set(1)
+	if c.connected != nil {
+		c.connected()
+	}
+}
+
+// disconnect drops the connection after err and starts reconnect, unless it
+// is down already.
+func (c *failoverConn) disconnect(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.down {
+		return
+	}
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.registered = false
+	c.down = true
+	glog.Warningf("Disconnected from the coordinator: %v", err)
+	coordinatorConnectedGauge.Set(0)
+	go c.reconnect()
+}
+
+// reconnect tries to register again with exponential backoff until one of
+// the replicas answers.
+func (c *failoverConn) reconnect() {
+	backoff := reconnectMin
+	for {
+		// jitter keeps members from calling a restarted coordinator at once.
+		c.clk.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		coordinatorReconnectCounter.Inc()
+		err := c.try(func(CoordinatorConn) error { return nil })
+		if err == nil {
+			c.up()
+			return
+		}
+		glog.V(1).Infof("Reconnect to the coordinator failed, retry in %v: %v", backoff, err)
+		if backoff *= 2; backoff > reconnectMax {
+			backoff = reconnectMax
+		}
+	}
+}
+
+// current returns the connection calls are made on, dialing the first
+// replica if there is none, and whether it is registered.
+func (c *failoverConn) current() (CoordinatorConn, string, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		conn, err := c.dial(c.addrs[0])
+		if err != nil {
+			return nil, c.addrs[0], false, err
+		}
+		c.addr, c.conn, c.registered = c.addrs[0], conn, false
+	}
+	return c.conn, c.addr, c.registered, nil
+}
+
+// switchTo replaces the connection to from with one to addr, unless
+// another call already replaced it. It returns the connection calls are
+// made on then, its address and whether it is registered.
+func (c *failoverConn) switchTo(from, addr string) (CoordinatorConn, string, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn != nil && c.addr != from {
+		return c.conn, c.addr, c.registered, nil
+	}
+	conn, err := c.dial(addr)
+	if err != nil {
+		return nil, addr, false, err
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	glog.Infof("Switch from coordinator %s to %s.", from, addr)
+	c.addr, c.conn, c.registered = addr, conn, false
+	return conn, addr, false, nil
+}
+
+// setRegistered records whether the cluster is registered over conn, if
+// calls are still made on it.
+func (c *failoverConn) setRegistered(conn CoordinatorConn, registered bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == conn {
+		c.registered = registered
+	}
+}
+
+// call makes f on the leader, see try, and disconnects if no replica
+// could be reached.
+func (c *failoverConn) call(f func(CoordinatorConn) error) error {
+	if !c.Connected() {
+		return errDisconnected
+	}
+	err := c.try(f)
+	if _, redirected := federationpb.LeaderOf(err); redirected || federationpb.Unreachable(err) {
+		c.disconnect(err)
+	}
+	return err
+}
+
+// try makes f on the leader, registering first on a new connection. It
+// follows redirects and tries every replica at most once, and registers
+// again once if the coordinator does not know the cluster.
+func (c *failoverConn) try(f func(CoordinatorConn) error) error {
+	conn, addr, registered, err := c.current()
+	tried := make(map[string]bool)
+	reregistered := false
+	for {
+		if conn != nil {
+			if !registered {
+				err = c.register(conn)
+				registered = err == nil
+				c.setRegistered(conn, registered)
+			}
+			if registered {
+				if err = f(conn); err == nil {
+					return nil
+				}
+			}
+			if federationpb.IsNotRegistered(err) && !reregistered {
+				glog.Warning("The coordinator does not know this cluster, register again.")
+				reregistered, registered = true, false
+				continue
+			}
+		}
+		tried[addr] = true
+		leader, redirected := federationpb.LeaderOf(err)
+		if !redirected && !federationpb.Unreachable(err) {
+			return err
+		}
+		next := ""
+		if leader != "" && !tried[leader] {
+			next = leader
+		} else {
+			for _, a := range c.addrs {
+				if !tried[a] {
+					next = a
+					break
+				}
+			}
+		}
+		if next == "" {
+			return err
+		}
+		conn, addr, registered, err = c.switchTo(addr, next)
+	}
+}
+
+func (c *failoverConn) Heartbeat(cluster types.Cluster) error {
+	return c.call(func(conn CoordinatorConn) error {
+		return conn.Heartbeat(cluster)
+	})
+}
+
+func (c *failoverConn) DeregisterCluster(id string) error {
+	return c.call(func(conn CoordinatorConn) error {
+		return conn.DeregisterCluster(id)
+	})
+}
+
+func (c *failoverConn) UploadPod(pod types.InterPod) (float64, error) {
+	var weight float64
+	err := c.call(func(conn CoordinatorConn) (err error) {
+		weight, err = conn.UploadPod(pod)
+		return err
+	})
+	return weight, err
+}
+
+func (c *failoverConn) UploadPodGroup(group types.InterPodGroup) (types.GroupReservation, error) {
+	var reservation types.GroupReservation
+	err := c.call(func(conn CoordinatorConn) (err error) {
+		reservation, err = conn.UploadPodGroup(group)
+		return err
+	})
+	return reservation, err
+}
+
+func (c *failoverConn) ReleasePod(pod types.InterPod) error {
+	return c.call(func(conn CoordinatorConn) error {
+		return conn.ReleasePod(pod)
+	})
+}
+
+func (c *failoverConn) ConfirmPlacement(placement types.Placement) error {
+	return c.call(func(conn CoordinatorConn) error {
+		return conn.ConfirmPlacement(placement)
+	})
+}
+
+func (c *failoverConn) Reconcile(state types.MemberState) (types.Reconciliation, error) {
+	var reconciliation types.Reconciliation
+	err := c.call(func(conn CoordinatorConn) (err error) {
+		reconciliation, err = conn.Reconcile(state)
+		return err
+	})
+	return reconciliation, err
+}
+
+func (c *failoverConn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.registered = nil, false
+	return err
+}
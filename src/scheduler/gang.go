@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"strconv"
+	"types"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// A pod joins a gang with the pod-group label, the group is scheduled once
+// min-member of its pods are pending. The min-member annotation may be set
+// on any pod of the group and defaults to 1.
+const (
+	podGroupLabel       = "federation-scheduler/pod-group"
+	minMemberAnnotation = "federation-scheduler/min-member"
+)
+
+type pendingGroup struct {
+	types.PodGroup
+	queued bool
+}
+
+func podGroupOf(pod *v1.Pod) (string, int) {
+	group, ok := pod.Labels[podGroupLabel]
+	if !ok {
+		return "", 0
+	}
+	minMember, err := strconv.Atoi(pod.Annotations[minMemberAnnotation])
+	if err != nil || minMember < 1 {
+		minMember = 1
+	}
+	return group, minMember
+}
+
+func groupKey(uid, group string) string {
+	return uid + "/" + group
+}
+
+// collectGroupPod holds pod back until its group has MinMember pods, and
+// then returns the placeholder standing for the group in usersPodsQ. Pods
+// arriving after their group was queued are scheduled on their own until
+// takeGroup takes it, later ones start the group anew.
+func (m *Member) collectGroupPod(pod types.Pod) (types.Pod, bool) {
+	m.groupsLock.Lock()
+	defer m.groupsLock.Unlock()
+	key := groupKey(pod.Uid, pod.Group)
+	group, ok := m.podGroups[key]
+	if !ok {
+		group = &pendingGroup{PodGroup: types.PodGroup{Name: pod.Group, Uid: pod.Uid}}
+		m.podGroups[key] = group
+	}
+	if group.queued {
+		return pod, true
+	}
+	if pod.MinMember > group.MinMember {
+		group.MinMember = pod.MinMember
+	}
+	group.Pods = append(group.Pods, pod)
+	glog.Infof("group %s has %d/%d pods", key, len(group.Pods), group.MinMember)
+	if len(group.Pods) < group.MinMember {
+		return types.Pod{}, false
+	}
+	m.readyGroups[key] = group.PodGroup
+	group.queued = true
+	group.Pods = nil
+	return types.Pod{Uid: pod.Uid, Group: pod.Group}, true
+}
+
+func (m *Member) takeGroup(placeholder types.Pod) (types.PodGroup, bool) {
+	m.groupsLock.Lock()
+	defer m.groupsLock.Unlock()
+	key := groupKey(placeholder.Uid, placeholder.Group)
+	group, ok := m.readyGroups[key]
+	delete(m.readyGroups, key)
+	delete(m.podGroups, key)
+	return group, ok
+}
+
+// forgetGroups drops the groups of a deleted tenant.
+func (m *Member) forgetGroups(uid string) {
+	m.groupsLock.Lock()
+	defer m.groupsLock.Unlock()
+	for key, group := range m.podGroups {
+		if group.Uid == uid {
+			delete(m.podGroups, key)
+		}
+	}
+	for key, group := range m.readyGroups {
+		if group.Uid == uid {
+			delete(m.readyGroups, key)
+		}
+	}
+}
+
+// placeGroup finds a local node for every pod of a group, or reports false
+// if any of them does not fit.
+func (m *Member) placeGroup(pods []types.Pod, nodes []types.Node) ([]types.Node, bool) {
+	allocated := m.kube.allocation()
+	placement := make([]types.Node, 0, len(pods))
+	for _, pod := range pods {
+		node, ok := m.selectNodeWith(pod, nodes, allocated)
+		if !ok {
+			return nil, false
+		}
+		allocated[node.Name] = allocated[node.Name].Add(pod.Request())
+		placement = append(placement, node)
+	}
+	return placement, true
+}
+
+// scheduleGroup places all pods of the group of w on local nodes or, failing
+// that, has the coordinator reserve room for all of them across the
+// federation. It reports false if the group has to wait.
+func (m *Member) scheduleGroup(w *waitingPod) (float64, bool) {
+	group := *w.group
+	if nodes, ok := m.placeGroup(group.Pods, m.kube.getNodes()); ok {
+		for i, pod := range group.Pods {
+			m.schedulePodToNode(pod, nodes[i])
+			m.recordScheduled(pod, "local")
+		}
+		m.Heartbeat()
+		return 0, true
+	}
+	if m.outsourcing(group.Uid) {
+		// if cluster doesn't have enough resourse, outsource the group.
+		reservation, err := m.UploadPodGroup(group)
+		if err == nil {
+			accepted := make(map[string]bool, len(reservation.Results))
+			for _, result := range reservation.Results {
+				accepted[result.Pod.Name] = m.outsourcePodTo(result) == nil
+			}
+			now := m.clk.Now()
+			for _, pod := range group.Pods {
+				m.recordScheduled(pod, "outsourced")
+				if !accepted[pod.Name] {
+					// the coordinator placed it, Reconcile sends its
+					// result again.
+					m.outsourcedToLock.Lock()
+					m.awaiting[pod.Name] = awaitingPod{pod: pod, since: now, kept: true}
+					m.outsourcedToLock.Unlock()
+					continue
+				}
+				m.kube.deletePodByName(pod.Name, pod.Uid)
+			}
+			return reservation.Weight, true
+		}
+		glog.Infof("group %s/%s is not placed: %v", group.Uid, group.Name, err)
+	}
+	return 0, false
+}
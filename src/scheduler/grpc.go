@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+	"types"
+	"types/federationpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// memberServer serves the gRPC protocol with the handlers of the net/rpc
+// Server, which stays registered for peers that predate it.
+type memberServer struct {
+	federationpb.UnimplementedMemberServer
+	m *Member
+}
+
+// server is the net/rpc Server of the caller of ctx.
+func (s *memberServer) server(ctx context.Context) *Server {
+	return s.m.Server(federationpb.CallerIdentity(ctx))
+}
+
+// statusError turns a handler error into a gRPC status, code is used for
+// errors other than a denied permission.
+func statusError(err error, code codes.Code) error {
+	if err == federationpb.ErrPermissionDenied {
+		code = codes.PermissionDenied
+	}
+	return status.Error(code, err.Error())
+}
+
+func (s *memberServer) ReturnScheduleResult(ctx context.Context, req *federationpb.ScheduleResult) (*federationpb.Empty, error) {
+	result := federationpb.DecodeScheduleResult(req)
+	var reply int
+	if err := s.server(ctx).ReturnScheduleResult(&result, &reply); err != nil {
+		return nil, statusError(err, codes.Unavailable)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *memberServer) CreatePod(ctx context.Context, req *federationpb.CreatePodRequest) (*federationpb.Empty, error) {
+	pod, err := federationpb.DecodeCreatePod(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var reply int
+	if err := s.server(ctx).CreatePod(&pod, &reply); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *memberServer) ReturnScheduleData(ctx context.Context, req *federationpb.ScheduleData) (*federationpb.Empty, error) {
+	data := federationpb.DecodeScheduleData(req)
+	var reply int
+	if err := s.server(ctx).ReturnScheduleData(&data, &reply); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *memberServer) ReleasePod(ctx context.Context, req *federationpb.Pod) (*federationpb.Empty, error) {
+	pod := federationpb.DecodePod(req)
+	var reply int
+	if err := s.server(ctx).ReleasePod(&pod, &reply); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return &federationpb.Empty{}, nil
+}
+
+func (s *memberServer) TenantShares(ctx context.Context, req *federationpb.Empty) (*federationpb.TenantSharesResponse, error) {
+	var tenants []types.TenantStatus
+	if err := s.server(ctx).TenantShares(new(int), &tenants); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+	return federationpb.EncodeTenantShares(tenants), nil
+}
+
+// callContext bounds a gRPC call by timeout.
+func callContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+type grpcCoordinator struct {
+	conn    *grpc.ClientConn
+	client  federationpb.CoordinatorClient
+	timeout time.Duration
+}
+
+func dialCoordinatorGrpc(addr string, config *tls.Config, timeout time.Duration) (CoordinatorConn, error) {
+	conn, err := federationpb.Dial(addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return grpcCoordinator{conn, federationpb.NewCoordinatorClient(conn), timeout}, nil
+}
+
+func (c grpcCoordinator) RegisterCluster(cluster types.Cluster) (uint32, error) {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	resp, err := c.client.RegisterCluster(ctx, &federationpb.RegisterClusterRequest{
+		Cluster:    federationpb.EncodeCluster(cluster),
+		MinVersion: federationpb.MinProtocolVersion,
+		MaxVersion: federationpb.ProtocolVersion,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+func (c grpcCoordinator) Heartbeat(cluster types.Cluster) error {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	_, err := c.client.Heartbeat(ctx, &federationpb.HeartbeatRequest{Cluster: federationpb.EncodeCluster(cluster)})
+	return err
+}
+
+func (c grpcCoordinator) DeregisterCluster(id string) error {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	_, err := c.client.DeregisterCluster(ctx, &federationpb.DeregisterClusterRequest{ClusterId: id})
+	return err
+}
+
+func (c grpcCoordinator) UploadPod(pod types.InterPod) (float64, error) {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	resp, err := c.client.UploadPod(ctx, &federationpb.UploadPodRequest{ClusterId: pod.ClusterId, Pod: federationpb.EncodePod(pod.Pod)})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Weight, nil
+}
+
+func (c grpcCoordinator) UploadPodGroup(group types.InterPodGroup) (types.GroupReservation, error) {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	resp, err := c.client.UploadPodGroup(ctx, &federationpb.UploadPodGroupRequest{ClusterId: group.ClusterId, Group: federationpb.EncodePodGroup(group.PodGroup)})
+	if err != nil {
+		return types.GroupReservation{}, err
+	}
+	return federationpb.DecodeGroupReservation(resp), nil
+}
+
+func (c grpcCoordinator) ReleasePod(pod types.InterPod) error {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	_, err := c.client.ReleasePod(ctx, &federationpb.ReleasePodRequest{ClusterId: pod.ClusterId, Pod: federationpb.EncodePod(pod.Pod)})
+	return err
+}
+
+func (c grpcCoordinator) ConfirmPlacement(placement types.Placement) error {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	_, err := c.client.ConfirmPlacement(ctx, &federationpb.ConfirmPlacementRequest{
+		ClusterId:     placement.ClusterId,
+		Pod:           federationpb.EncodePod(placement.Pod),
+		DestClusterId: placement.DestClusterId,
+	})
+	return err
+}
+
+func (c grpcCoordinator) Reconcile(state types.MemberState) (types.Reconciliation, error) {
+	ctx, cancel := callContext(c.timeout)
+	defer cancel()
+	resp, err := c.client.Reconcile(ctx, federationpb.EncodeMemberState(state))
+	if err != nil {
+		return types.Reconciliation{}, err
+	}
+	return federationpb.DecodeReconciliation(resp), nil
+}
+
+func (c grpcCoordinator) Close() error {
+	return c.conn.Close()
+}
+
+type grpcMember struct {
+	conn    *grpc.ClientConn
+	client  federationpb.MemberClient
+	timeout time.Duration
+}
+
+func dialMemberGrpc(addr string, config *tls.Config, timeout time.Duration) (MemberConn, error) {
+	conn, err := federationpb.Dial(addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return grpcMember{conn, federationpb.NewMemberClient(conn), timeout}, nil
+}
+
+func (m grpcMember) CreatePod(pod types.OutsourcePod) error {
+	ctx, cancel := callContext(m.timeout)
+	defer cancel()
+	_, err := m.client.CreatePod(ctx, federationpb.EncodeCreatePod(pod))
+	return err
+}
+
+func (m grpcMember) ReturnScheduleData(data types.ScheduleData) error {
+	ctx, cancel := callContext(m.timeout)
+	defer cancel()
+	_, err := m.client.ReturnScheduleData(ctx, federationpb.EncodeScheduleData(data))
+	return err
+}
+
+func (m grpcMember) ReleasePod(pod types.Pod) error {
+	ctx, cancel := callContext(m.timeout)
+	defer cancel()
+	_, err := m.client.ReleasePod(ctx, federationpb.EncodePod(pod))
+	return err
+}
+
+func (m grpcMember) Close() error {
+	return m.conn.Close()
+}
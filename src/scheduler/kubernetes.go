@@ -1,64 +1,57 @@
 package scheduler
 
 import (
-	"flag"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 
 	"types"
 )
 
-var (
+// Kube is the member's connection to its Kubernetes cluster together with
+// what it tracks there: the nodes pods may be bound to, what is allocated on
+// them and the pods run on behalf of other clusters.
+type Kube struct {
+	client                     kubernetes.Interface
 	allocatedResource          map[string]types.Resource
+	allocatedLock              sync.Mutex // guards allocatedResource
 	availableNodes             []types.Node
-	clientset                  *kubernetes.Clientset
+	nodesLock                  sync.RWMutex // guards availableNodes, which is replaced rather than modified in place
 	pendingPodCh, deletedPodCh chan types.Pod
-	otherClustersPod           map[string]string
-)
-
-func init() {
-	allocatedResource = make(map[string]types.Resource)
-	availableNodes = make([]types.Node, 0)
-	pendingPodCh = make(chan types.Pod, 500)
-	deletedPodCh = make(chan types.Pod, 500)
-	otherClustersPod = make(map[string]string)
+	otherClustersPod           map[string]peer           // pod name -> source cluster
+	outsourcedPods             map[string]types.InterPod // pods run here on behalf of other clusters
+	importsLock                sync.Mutex                // guards otherClustersPod and outsourcedPods
 }
 
-func Init() {
-	var kubeconfig *string
-	if home := homeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
-	flag.Parse()
-
-	// use the current context in kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
-	if err != nil {
-		glog.Error(err.Error())
+// NewKube returns a Kube talking to the cluster through client, which may
+// be a fake clientset.
+func NewKube(client kubernetes.Interface) *Kube {
+	return &Kube{
+		client:            client,
+		allocatedResource: make(map[string]types.Resource),
+		availableNodes:    make([]types.Node, 0),
+		pendingPodCh:      make(chan types.Pod, 500),
+		deletedPodCh:      make(chan types.Pod, 500),
+		otherClustersPod:  make(map[string]peer),
+		outsourcedPods:    make(map[string]types.InterPod),
 	}
+}
 
-	// create the clientset
-	clientset, err = kubernetes.NewForConfig(config)
-	if err != nil {
-		glog.Error(err.Error())
+func defaultKubeconfig() string {
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, ".kube", "config")
 	}
-	glog.Info("clientset is created successfully.")
-
-	initAllocatedResource()
-	initNodes()
-	initShare()
-	go updateAllocatedResource()
+	return ""
 }
 
 func homeDir() string {
@@ -68,78 +61,184 @@ func homeDir() string {
 	return os.Getenv("USERPROFILE") // windows
 }
 
-func initAllocatedResource() {
-	pods := getRunningPods()
+func (k *Kube) initAllocatedResource() {
+	pods := k.getRunningPods()
 	for _, pod := range pods {
-		nodeName := pod.NodeName
-		var res types.Resource
-		res, ok := allocatedResource[nodeName]
-		if ok {
-			res.MilliCpu += pod.RequestMilliCpu
-			res.Memory += pod.RequestMemory
-			allocatedResource[nodeName] = res
-		} else {
-			res.MilliCpu = pod.RequestMilliCpu
-			res.Memory = pod.RequestMemory
-			allocatedResource[nodeName] = res
-		}
+		k.allocate(pod.NodeName, pod.Request())
 		glog.Infof("%v is running.\n", pod)
 	}
-	for k, v := range allocatedResource {
-		glog.Infof("%s has used : %v", k, v)
+	for name, res := range k.allocation() {
+		glog.Infof("%s has used : %v", name, res)
 	}
 	glog.Info("AllocatedResource initialization is completed.")
 }
 
-func initNodes() {
-	nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+// allocate adds res to what is allocated on node and returns the sum.
+func (k *Kube) allocate(node string, res types.Resource) types.Resource {
+	k.allocatedLock.Lock()
+	defer k.allocatedLock.Unlock()
+	k.allocatedResource[node] = k.allocatedResource[node].Add(res)
+	return k.allocatedResource[node]
+}
+
+// free takes res off what is allocated on node and returns the rest.
+func (k *Kube) free(node string, res types.Resource) types.Resource {
+	k.allocatedLock.Lock()
+	defer k.allocatedLock.Unlock()
+	k.allocatedResource[node] = k.allocatedResource[node].Sub(res)
+	return k.allocatedResource[node]
+}
+
+// allocation returns a copy of what is allocated on each node.
+func (k *Kube) allocation() map[string]types.Resource {
+	k.allocatedLock.Lock()
+	defer k.allocatedLock.Unlock()
+	allocated := make(map[string]types.Resource, len(k.allocatedResource))
+	for node, res := range k.allocatedResource {
+		allocated[node] = res
+	}
+	return allocated
+}
+
+func (k *Kube) initNodes() {
+	nodes, err := k.client.CoreV1().Nodes().List(metav1.ListOptions{})
 	if err != nil {
 		glog.Error(err.Error())
 	}
-	for _, node := range nodes.Items {
-		newNode := types.Node{
-			Name: node.Name,
-			Resource: types.Resource{
-				MilliCpu: node.Status.Allocatable.Cpu().MilliValue(),
-				Memory:   node.Status.Allocatable.Memory().Value() / 1024 / 1024,
-			},
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if isNodeAvailable(node) {
+			k.availableNodes = append(k.availableNodes, toNode(node))
+		} else {
+			glog.Infof("%s is not available.", node.Name)
 		}
-		availableNodes = append(availableNodes, newNode)
 	}
-	for _, node := range availableNodes {
+	sort.Slice(k.availableNodes, func(i, j int) bool { return k.availableNodes[i].Name < k.availableNodes[j].Name })
+	for _, node := range k.availableNodes {
 		glog.Infof("%s's total resource : %v", node.Name, node.Resource)
 	}
 	glog.Info("AvailableNodes initialization is completed.")
 }
 
-func getRunningPods() []types.Pod {
-	pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+func toNode(node *v1.Node) types.Node {
+	return types.Node{
+		Name:     node.Name,
+		Resource: types.NewResource(node.Status.Allocatable),
+		Labels:   node.Labels,
+		Taints:   node.Spec.Taints,
+	}
+}
+
+// isNodeAvailable reports whether pods may be bound to node: it must be
+// neither cordoned nor NotReady.
+func isNodeAvailable(node *v1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// WatchNodes keeps availableNodes in sync with the cluster and reports
+// capacity changes to the coordinator right away.
+func (m *Member) WatchNodes() {
+	// In case the eventChan is closed sometime.
+	for {
+		watchInt, err := m.kube.client.CoreV1().Nodes().Watch(metav1.ListOptions{})
+		if err != nil {
+			glog.Error(err.Error())
+			m.clk.Sleep(time.Second)
+			continue
+		}
+		for event := range watchInt.ResultChan() {
+			m.handleNodeEvent(event)
+		}
+		glog.Warning("watchNodes exit.")
+	}
+}
+
+func (m *Member) handleNodeEvent(event watch.Event) {
+	node, ok := event.Object.(*v1.Node)
+	if !ok {
+		return
+	}
+	available := event.Type != "DELETED" && isNodeAvailable(node)
+	if m.kube.setNode(toNode(node), available) {
+		m.fixTotalResource()
+		glog.Infof("%s available:%t, total resource:%v", node.Name, available, m.getTotalResource())
+		m.Heartbeat()
+	}
+}
+
+// setNode adds, updates or removes a node in availableNodes and returns
+// whether anything changed.
+func (k *Kube) setNode(node types.Node, available bool) bool {
+	k.nodesLock.Lock()
+	defer k.nodesLock.Unlock()
+	nodes := make([]types.Node, 0, len(k.availableNodes)+1)
+	found, changed := false, false
+	for _, n := range k.availableNodes {
+		if n.Name != node.Name {
+			nodes = append(nodes, n)
+			continue
+		}
+		found = true
+		if !available {
+			changed = true
+			continue
+		}
+		if !reflect.DeepEqual(n, node) {
+			changed = true
+		}
+		nodes = append(nodes, node)
+	}
+	if !found && available {
+		nodes = append(nodes, node)
+		changed = true
+	}
+	if changed {
+		k.availableNodes = nodes
+	}
+	return changed
+}
+
+func (k *Kube) getRunningPods() []types.Pod {
+	pods, err := k.client.CoreV1().Pods("").List(metav1.ListOptions{})
 	if err != nil {
 		glog.Error(err.Error())
 	}
 	runningPods := make([]types.Pod, 0)
 	for _, pod := range pods.Items {
 		if pod.Status.Phase == v1.PodRunning {
-			var requestsMilliCpu, requestsMemory int64
-			for _, ctn := range pod.Spec.Containers {
-				requestsMilliCpu += ctn.Resources.Requests.Cpu().MilliValue()
-				requestsMemory += ctn.Resources.Requests.Memory().Value() / 1024 / 1024
-			}
-			newPod := types.Pod{
-				Name:            pod.Name,
-				Uid:             pod.Namespace,
-				NodeName:        pod.Spec.NodeName,
-				RequestMilliCpu: requestsMilliCpu,
-				RequestMemory:   requestsMemory,
-			}
-			runningPods = append(runningPods, newPod)
+			runningPods = append(runningPods, toPod(&pod))
 		}
 	}
 	return runningPods
 }
 
-func getPodByName(podName, namespace string) (v1.Pod, error) {
-	pod, err := clientset.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+func toPod(pod *v1.Pod) types.Pod {
+	request := types.NewPodRequest(pod.Spec)
+	group, minMember := podGroupOf(pod)
+	return types.Pod{
+		Name:            pod.Name,
+		Uid:             pod.Namespace,
+		NodeName:        pod.Spec.NodeName,
+		RequestMilliCpu: request.MilliCpu,
+		RequestMemory:   request.Memory,
+		Requests:        request,
+		SchedulerName:   pod.Spec.SchedulerName,
+		Constraints:     types.NewNodeConstraints(pod.Spec),
+		Group:           group,
+		MinMember:       minMember,
+	}
+}
+
+func (k *Kube) getPodByName(podName, namespace string) (v1.Pod, error) {
+	pod, err := k.client.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
 	if err != nil {
 		glog.Error(err)
 	} else {
@@ -148,8 +247,8 @@ func getPodByName(podName, namespace string) (v1.Pod, error) {
 	return *pod, err
 }
 
-func deletePodByName(podName, namespace string) error {
-	err := clientset.CoreV1().Pods(namespace).Delete(podName, &metav1.DeleteOptions{})
+func (k *Kube) deletePodByName(podName, namespace string) error {
+	err := k.client.CoreV1().Pods(namespace).Delete(podName, &metav1.DeleteOptions{})
 	if err != nil {
 		glog.Error(err)
 	} else {
@@ -158,88 +257,154 @@ func deletePodByName(podName, namespace string) error {
 	return err
 }
 
-func createPod(outsourcePod types.OutsourcePod) error {
+func (m *Member) createPod(outsourcePod types.OutsourcePod) error {
 	pod := outsourcePod.Pod
 	podName := pod.Name
-	if outsourcePod.ClusterId != clusterId {
+	if outsourcePod.ClusterId != m.clusterId {
 		podName = outsourcePod.ClusterId + "-" + pod.Name
 	}
-	otherClustersPod[podName] = outsourcePod.SourceIP
-	containers := make([]v1.Container, 0)
-	resourceList := make(map[v1.ResourceName]resource.Quantity)
-	resourceList["cpu"] = *resource.NewMilliQuantity(outsourcePod.MilliCpu, resource.DecimalSI)
-	resourceList["memory"] = *resource.NewQuantity(outsourcePod.Memory*1024*1024, resource.BinarySI)
-	for _, c := range pod.Spec.Containers {
-		container := v1.Container{
-			Name:    c.Name,
-			Image:   c.Image,
-			Command: c.Command,
-			Args:    c.Args,
-			Resources: v1.ResourceRequirements{
-				Requests: resourceList,
-			},
-			ImagePullPolicy: c.ImagePullPolicy,
-		}
-		containers = append(containers, container)
+	newPod, err := importPod(outsourcePod, podName, m.stripFields)
+	if err != nil {
+		return err
 	}
-	newPod := &v1.Pod{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "v1",
-			Kind:       "Pod",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: "other-clusters",
-		},
-		Spec: v1.PodSpec{
-			SchedulerName: pod.Spec.SchedulerName,
-			RestartPolicy: pod.Spec.RestartPolicy,
-			Containers:    containers,
-		},
+	// the pod is known before its watch event arrives, and forgotten again
+	// if it is not created.
+	m.kube.importsLock.Lock()
+	m.kube.otherClustersPod[podName] = peer{
+		id:      outsourcePod.ClusterId,
+		addr:    clusterAddr(outsourcePod.SourceIP, outsourcePod.SourcePort),
+		version: outsourcePod.SourceVersion,
+	}
+	m.kube.outsourcedPods[podName] = types.InterPod{
+		Pod:       types.Pod{Name: pod.Name, Uid: pod.Namespace, Requests: outsourcePod.Resource},
+		ClusterId: outsourcePod.ClusterId,
+	}
+	m.kube.importsLock.Unlock()
+	if _, err = m.kube.client.CoreV1().Pods(outsourceNamespace).Create(newPod); err != nil {
+		m.kube.importsLock.Lock()
+		delete(m.kube.otherClustersPod, podName)
+		delete(m.kube.outsourcedPods, podName)
+		m.kube.importsLock.Unlock()
 	}
-	_, err := clientset.CoreV1().Pods("other-clusters").Create(newPod)
 	return err
 }
 
-func updateAllocatedResource() {
-	for pod := range deletedPodCh {
-		nodeName := pod.NodeName
-		res, _ := allocatedResource[nodeName]
-		res.MilliCpu -= pod.RequestMilliCpu
-		res.Memory -= pod.RequestMemory
-		allocatedResource[nodeName] = res
-		Heartbeat()
-		glog.Info("---------", nodeName, ":", res)
+// releaseOutsourcedPod tells the coordinator that a pod run on behalf of
+// another cluster no longer holds resources here.
+func (m *Member) releaseOutsourcedPod(podName string) {
+	m.kube.importsLock.Lock()
+	interPod, ok := m.kube.outsourcedPods[podName]
+	delete(m.kube.outsourcedPods, podName)
+	source := m.kube.otherClustersPod[podName]
+	m.kube.importsLock.Unlock()
+	if !ok {
+		return
 	}
+	m.ReleasePod(interPod)
+	m.ReleaseSourcePod(interPod.Pod, source)
 }
 
-func getNodes() []types.Node {
-	return availableNodes
+// sourceOf returns the source cluster of a pod run here for another
+// cluster.
+func (k *Kube) sourceOf(podName string) (peer, bool) {
+	k.importsLock.Lock()
+	defer k.importsLock.Unlock()
+	source, ok := k.otherClustersPod[podName]
+	return source, ok
 }
 
-func getNamespaces() []string {
-	nss, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{})
+// imports returns the pods run here on behalf of other clusters.
+func (k *Kube) imports() []types.InterPod {
+	k.importsLock.Lock()
+	defer k.importsLock.Unlock()
+	pods := make([]types.InterPod, 0, len(k.outsourcedPods))
+	for _, pod := range k.outsourcedPods {
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// UpdateAllocatedResource frees the nodes of deleted and finished pods.
+func (m *Member) UpdateAllocatedResource() {
+	for pod := range m.kube.deletedPodCh {
+		m.freePod(pod)
+	}
+}
+
+func (m *Member) freePod(pod types.Pod) {
+	nodeName := pod.NodeName
+	res := m.kube.free(nodeName, pod.Request())
+	m.send(m.finishedPodCh, pod)
+	m.Heartbeat()
+	glog.Info("---------", nodeName, ":", res)
+}
+
+func (k *Kube) getNodes() []types.Node {
+	k.nodesLock.RLock()
+	defer k.nodesLock.RUnlock()
+	return k.availableNodes
+}
+
+func (m *Member) getNamespaces() []v1.Namespace {
+	nss, err := m.kube.client.CoreV1().Namespaces().List(metav1.ListOptions{})
 	if err != nil {
 		glog.Error(err.Error())
 	}
-	namespaces := make([]string, 0)
+	namespaces := make([]v1.Namespace, 0)
 	for _, ns := range nss.Items {
-		name := ns.Name
-		if name != "default" && name != "kube-public" && name != "kube-system" {
-			namespaces = append(namespaces, name)
+		if m.isTenant(ns.Name) {
+			namespaces = append(namespaces, ns)
 		}
 	}
 	return namespaces
 }
 
-func schedulePodToNode(pod types.Pod, node types.Node) {
+func (m *Member) isTenant(namespace string) bool {
+	return !m.excludedNamespaces[namespace]
+}
+
+// WatchNamespaces registers tenants as their namespaces are created, keeps
+// their weights and overrides of local in sync with namespace annotations
+// and removes them again when the namespace is deleted.
+func (m *Member) WatchNamespaces() {
+	// In case the eventChan is closed sometime.
+	for {
+		watchInt, err := m.kube.client.CoreV1().Namespaces().Watch(metav1.ListOptions{})
+		if err != nil {
+			glog.Error(err.Error())
+			m.clk.Sleep(time.Second)
+			continue
+		}
+		for event := range watchInt.ResultChan() {
+			m.handleNamespaceEvent(event)
+		}
+		glog.Warning("watchNamespaces exit.")
+	}
+}
+
+func (m *Member) handleNamespaceEvent(event watch.Event) {
+	ns, ok := event.Object.(*v1.Namespace)
+	if !ok || !m.isTenant(ns.Name) {
+		return
+	}
+	switch event.Type {
+	case "ADDED", "MODIFIED":
+		m.tenantCh <- tenantEvent{uid: ns.Name, weight: namespaceWeight(ns.Annotations, ns.Labels),
+			local: namespaceLocal(ns.Annotations, ns.Labels)}
+	case "DELETED":
+		m.tenantCh <- tenantEvent{uid: ns.Name, deleted: true}
+	}
+}
+
+func (m *Member) schedulePodToNode(pod types.Pod, node types.Node) {
 	binding := v1.Binding{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
 			Kind:       "Binding",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: pod.Name,
+			Name:      pod.Name,
+			Namespace: pod.Uid,
 		},
 		Target: v1.ObjectReference{
 			APIVersion: "v1",
@@ -247,100 +412,102 @@ func schedulePodToNode(pod types.Pod, node types.Node) {
 			Name:       node.Name,
 		},
 	}
-	err := clientset.CoreV1().Pods(pod.Uid).Bind(&binding)
+	err := m.kube.client.CoreV1().Pods(pod.Uid).Bind(&binding)
 	if err != nil {
 		glog.Error(err.Error())
 	}
-	res, _ := allocatedResource[node.Name]
-	res.MilliCpu += pod.RequestMilliCpu
-	res.Memory += pod.RequestMemory
-	allocatedResource[node.Name] = res
+	res := m.kube.allocate(node.Name, pod.Request())
 	glog.Info("+++++++++", node.Name, ":", res)
 	glog.Infof("Successfully schedule %s to %s", pod.Name, node.Name)
 	executeData := types.ExecuteData{
 		Pod:         pod,
-		CurrentTime: time.Now().Unix(),
+		CurrentTime: m.clk.Now().Unix(),
 		Status:      "running",
 	}
-	executeDataQ <- executeData
+	m.queueExecuteData(executeData)
 }
 
-func WatchPods() {
+func (m *Member) WatchPods() {
 	// In case the eventChan is closed sometime.
 	for {
-		watchInt, err := clientset.CoreV1().Pods("").Watch(metav1.ListOptions{})
+		watchInt, err := m.kube.client.CoreV1().Pods("").Watch(metav1.ListOptions{})
 		if err != nil {
 			glog.Error(err.Error())
+			m.clk.Sleep(time.Second)
+			continue
+		}
+		for event := range watchInt.ResultChan() {
+			m.handlePodEvent(event)
 		}
-		eventChan := watchInt.ResultChan()
-		for event := range eventChan {
-			pod := event.Object.(*v1.Pod)
-			statusPhase := pod.Status.Phase
-			var requestsMilliCpu, requestsMemory int64
-			for _, ctn := range pod.Spec.Containers {
-				requestsMilliCpu += ctn.Resources.Requests.Cpu().MilliValue()
-				requestsMemory += ctn.Resources.Requests.Memory().Value() / 1024 / 1024
+		glog.Warning("watchPods exit.")
+	}
+}
+
+func (m *Member) handlePodEvent(event watch.Event) {
+	pod, ok := event.Object.(*v1.Pod)
+	if !ok {
+		return
+	}
+	statusPhase := pod.Status.Phase
+	newPod := toPod(pod)
+	switch event.Type {
+	case "ADDED":
+		if statusPhase == v1.PodPending && pod.Spec.SchedulerName != "default-scheduler" && pod.Spec.NodeName == "" {
+			// Need to be scheduled.
+			// pods this member created for other clusters are bound here
+			// whatever scheduler they name.
+			if _, created := m.kube.sourceOf(pod.Name); pod.Namespace == outsourceNamespace && (created || pod.Spec.SchedulerName == "federation-scheduler") {
+				m.send(m.highPriorityCh, newPod)
+				glog.Info("highPriorytyCh <- ", newPod)
+			} else {
+				m.send(m.kube.pendingPodCh, newPod)
+				glog.Info("pendingPodCh <- ", newPod)
 			}
-			newPod := types.Pod{
-				Name:            pod.Name,
-				Uid:             pod.Namespace,
-				NodeName:        pod.Spec.NodeName,
-				RequestMilliCpu: requestsMilliCpu,
-				RequestMemory:   requestsMemory,
+		}
+		if statusPhase == v1.PodPending && pod.Spec.NodeName == "" {
+			if pod.Namespace != "other-clusters" {
+				m.setPodInfo(*pod)
 			}
-			switch event.Type {
-			case "ADDED":
-				if statusPhase == v1.PodPending && pod.Spec.SchedulerName != "default-scheduler" && pod.Spec.NodeName == "" {
-					// Need to be scheduled.
-					if pod.Namespace == "other-clusters" && pod.Spec.SchedulerName == "federation-scheduler" {
-						highPriorityCh <- newPod
-						glog.Info("highPriorytyCh <- ", newPod)
-					} else {
-						pendingPodCh <- newPod
-						glog.Info("pendingPodCh <- ", newPod)
-					}
-				}
-				if statusPhase == v1.PodPending && pod.Spec.NodeName == "" {
-					if pod.Namespace != "other-clusters" {
-						podInfo[pod.Name] = *pod
-					}
-				}
-			case "MODIFIED":
-				if statusPhase == v1.PodSucceeded && pod.DeletionTimestamp == nil {
-					// Finished.
-					deletedPodCh <- newPod
-					glog.Info("deletedPodCh <- ", newPod)
-					// Return ScheduleData
-					createTime := pod.CreationTimestamp.ProtoTime().Seconds
-					startTime := pod.Status.StartTime.ProtoTime().Seconds
-					scheduleData := types.ScheduleData{
-						Pod:        newPod,
-						CreateTime: int64(createTime),
-						StartTime:  int64(startTime),
-						Status:     string(statusPhase),
-					}
-					_, ok := otherClustersPod[pod.Name]
-					if !ok {
-						scheduleDataQ <- scheduleData
-					} else {
-						ReturnScheduleData(scheduleData)
-					}
-					executeData := types.ExecuteData{
-						Pod:         newPod,
-						CurrentTime: time.Now().Unix(),
-						Status:      "finish",
-					}
-					executeDataQ <- executeData
-				}
-			case "DELETED":
-				if statusPhase == v1.PodRunning {
-					// Be deleted.
-					deletedPodCh <- newPod
-					glog.Info("deletedPodCh <- ", newPod)
-
-				}
+		}
+	case "MODIFIED":
+		if statusPhase == v1.PodSucceeded && pod.DeletionTimestamp == nil {
+			// Finished.
+			m.send(m.kube.deletedPodCh, newPod)
+			glog.Info("deletedPodCh <- ", newPod)
+			// Return ScheduleData
+			createTime := pod.CreationTimestamp.ProtoTime().Seconds
+			startTime := pod.Status.StartTime.ProtoTime().Seconds
+			scheduleData := types.ScheduleData{
+				Pod:        newPod,
+				CreateTime: int64(createTime),
+				StartTime:  int64(startTime),
+				Status:     string(statusPhase),
 			}
+			if _, ok := m.kube.sourceOf(pod.Name); !ok {
+				m.queueScheduleData(scheduleData)
+			} else {
+				m.ReturnScheduleData(scheduleData)
+			}
+			executeData := types.ExecuteData{
+				Pod:         newPod,
+				CurrentTime: m.clk.Now().Unix(),
+				Status:      "finish",
+			}
+			m.queueExecuteData(executeData)
+			m.releaseOutsourcedPod(pod.Name)
 		}
-		glog.Warning("watchPods exit.")
+		if statusPhase == v1.PodFailed && pod.DeletionTimestamp == nil {
+			m.send(m.kube.deletedPodCh, newPod)
+			glog.Info("deletedPodCh <- ", newPod)
+			m.releaseOutsourcedPod(pod.Name)
+		}
+	case "DELETED":
+		if statusPhase == v1.PodRunning {
+			// Be deleted.
+			m.send(m.kube.deletedPodCh, newPod)
+			glog.Info("deletedPodCh <- ", newPod)
+
+		}
+		m.releaseOutsourcedPod(pod.Name)
 	}
 }
@@ -0,0 +1,287 @@
+package scheduler
+
+import (
+	"clock"
+	"crypto/tls"
+	"events"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"types"
+	"types/federationpb"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Member is the scheduler of one member cluster: it binds the cluster's
+// pods to its nodes, hands those that do not fit to the coordinator and
+// runs the pods other clusters send. Its state is kept here rather than in
+// package variables so that several members can run in one process.
+type Member struct {
+	// configuration, see Config.
+	clusterId           string
+	clientAddress       string
+	clientPort          string
+	listenAddress       string
+	local               bool
+	clusterWeight       float64
+	protocol            string
+	rpcTimeout          time.Duration
+	heartbeatInterval   time.Duration
+	tlsConfig           *tls.Config // nil if TLS is disabled, callers are not authenticated then
+	coordinatorAddrs    []string    // the coordinator replicas, the first is called first
+	coordinatorIdentity string
+	operatorIdentity    string
+	metricsAddress      string
+	eventsConfig        events.Config
+	excludedNamespaces  map[string]bool // system namespaces that are not tenants
+	stripFields         map[string]bool
+	defaultScorer       string
+	schedulerScorer     map[string]string // schedulerName -> scorer name
+
+	kube            *Kube
+	clk             clock.Clock
+	dialCoordinator func(addr string) (CoordinatorConn, error)
+	dialMember      func(p peer) (MemberConn, error)
+
+	// tenants' shares, see share.go.
+	usersShare        map[string]float64
+	usersAllocatedRes map[string]types.Resource
+	usersWeight       map[string]float64
+	usersLocal        map[string]bool      // tenants overriding local, see localKey
+	usersPods         map[string]types.Pod // pods charged to usersAllocatedRes, keyed by podKey
+	finishedPodCh     chan types.Pod
+	tenantCh          chan tenantEvent
+	totalResource     types.Resource
+	totalLock         sync.RWMutex // guards totalResource
+
+	// tenants' queues, see scheduler.go.
+	usersPriorityQ types.PriorityQueue
+	usersPresent   map[string]bool //usersPresent[Uid] == true means that the Uid has been in usersPriorityQ.
+	usersActiveQ   chan string
+	usersPodsQ     map[string]chan types.Pod
+	highPriorityCh chan types.Pod
+	// pods that could not be placed yet, by tenant, and those of other
+	// clusters waiting for room.
+	parked        map[string]*waitingPod
+	parkedImports []*waitingPod
+
+	// gangs, see gang.go.
+	podGroups   map[string]*pendingGroup  // groups collecting their pods, by groupKey
+	readyGroups map[string]types.PodGroup // complete groups waiting in usersPodsQ
+	groupsLock  sync.Mutex
+
+	// the coordinator and our outsourced pods, see rpc.go.
+	coordinator *failoverConn
+	// protocolVersion is agreed with the coordinator at registration, 0 if
+	// it is called with net/rpc. Other members call us with it. It is
+	// guarded by outsourcedToLock, registration runs on reconnects.
+	protocolVersion uint32
+	// outsourcedTo maps our pods running on other clusters to the cluster
+	// the coordinator chose, the only one that may report on them.
+	outsourcedTo     map[string]string
+	outsourcedToLock sync.Mutex
+	// awaiting are our uploaded pods without a result yet, by name, and
+	// when they were uploaded. It is guarded by outsourcedToLock.
+	awaiting map[string]awaitingPod
+
+	// records, see scheduleResult.go.
+	podInfo                             map[string]v1.Pod // local pod
+	podInfoLock                         sync.RWMutex      // guards podInfo
+	scheduleDataQ                       chan types.ScheduleData
+	executeDataQ                        chan types.ExecuteData
+	userDataQ                           chan types.UserData
+	scheduleSink, clusterSink, userSink *events.Sink
+	startTime                           int64
+	totalWaitTime                       int64
+	usedCpu, usedMemory                 int64
+
+	// stepping is set by Watch, see step.go.
+	stepping    bool
+	watches     []watch.Interface
+	held        []heldPod
+	dispatching *types.Pod // a pending pod its tenant's queue has no room for
+
+	// tenants is what TenantShares reports, see metrics.go.
+	tenants     map[string]types.TenantStatus
+	tenantsLock sync.Mutex
+}
+
+var kubeconfig = flag.String("kubeconfig", defaultKubeconfig(), "absolute path to the kubeconfig file")
+
+// NewMember returns the member config describes, scheduling on the cluster
+// client talks to, which may be a fake clientset. Nothing is read from the
+// cluster before Start.
+func NewMember(config Config, client kubernetes.Interface) (*Member, error) {
+	if config.ListenAddress == "" {
+		config.ListenAddress = ":" + config.ClientPort
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	timeout, err := time.ParseDuration(config.RpcTimeout)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid rpc timeout %q", config.RpcTimeout)
+	}
+	interval, err := time.ParseDuration(config.HeartbeatInterval)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid heartbeat interval %q", config.HeartbeatInterval)
+	}
+	sinkConfig, err := config.sinkConfig()
+	if err != nil {
+		return nil, err
+	}
+	stripFields, err := parseStripFields(config.StripFields)
+	if err != nil {
+		return nil, err
+	}
+	schedulerScoring := config.SchedulerScoring
+	if schedulerScoring == nil {
+		schedulerScoring = make(map[string]string)
+	}
+	if err := checkScoring(config.Scoring, schedulerScoring); err != nil {
+		return nil, err
+	}
+	tlsConf, err := federationpb.LoadTLS(config.TLSCert, config.TLSKey, config.TLSCA)
+	if err != nil {
+		return nil, err
+	}
+	coordinatorAddrs := config.Coordinators
+	if len(coordinatorAddrs) == 0 {
+		coordinatorAddrs = []string{net.JoinHostPort(config.ServerAddress, config.ServerPort)}
+	}
+	excludedNamespaces := make(map[string]bool)
+	for _, ns := range config.ExcludedNamespaces {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			excludedNamespaces[ns] = true
+		}
+	}
+
+	m := &Member{
+		clusterId:           config.ClusterId,
+		clientAddress:       config.ClientAddress,
+		clientPort:          config.ClientPort,
+		listenAddress:       config.ListenAddress,
+		local:               config.Local,
+		clusterWeight:       config.Weight,
+		protocol:            config.Protocol,
+		rpcTimeout:          timeout,
+		heartbeatInterval:   interval,
+		tlsConfig:           tlsConf,
+		coordinatorAddrs:    coordinatorAddrs,
+		coordinatorIdentity: config.CoordinatorIdentity,
+		operatorIdentity:    config.OperatorIdentity,
+		metricsAddress:      config.MetricsAddress,
+		eventsConfig:        sinkConfig,
+		excludedNamespaces:  excludedNamespaces,
+		stripFields:         stripFields,
+		defaultScorer:       config.Scoring,
+		schedulerScorer:     schedulerScoring,
+
+		kube: NewKube(client),
+		clk:  clock.Real{},
+
+		usersShare:        make(map[string]float64),
+		usersAllocatedRes: make(map[string]types.Resource),
+		usersWeight:       make(map[string]float64),
+		usersLocal:        make(map[string]bool),
+		usersPods:         make(map[string]types.Pod),
+		finishedPodCh:     make(chan types.Pod, 500),
+		tenantCh:          make(chan tenantEvent, 100),
+
+		usersPresent:   make(map[string]bool),
+		usersActiveQ:   make(chan string, 10),
+		usersPodsQ:     make(map[string]chan types.Pod),
+		highPriorityCh: make(chan types.Pod, 10),
+		parked:         make(map[string]*waitingPod),
+
+		podGroups:   make(map[string]*pendingGroup),
+		readyGroups: make(map[string]types.PodGroup),
+
+		outsourcedTo: make(map[string]string),
+		awaiting:     make(map[string]awaitingPod),
+
+		podInfo:       make(map[string]v1.Pod),
+		scheduleDataQ: make(chan types.ScheduleData, 10),
+		executeDataQ:  make(chan types.ExecuteData, 10),
+		userDataQ:     make(chan types.UserData, 10),
+		startTime:     time.Now().Unix(),
+
+		tenants: make(map[string]types.TenantStatus),
+	}
+	m.dialCoordinator = m.dialReplica
+	m.dialMember = m.dialPeer
+	return m, nil
+}
+
+// SetClock makes the member read and wait on c instead of the wall clock.
+// It is called before Start.
+func (m *Member) SetClock(c clock.Clock) {
+	m.clk = c
+	m.startTime = c.Now().Unix()
+	m.eventsConfig.Clock = c
+}
+
+// SetCoordinatorDialer replaces how the member connects to a coordinator
+// replica at addr. It is called before Connect.
+func (m *Member) SetCoordinatorDialer(dial func(addr string) (CoordinatorConn, error)) {
+	m.dialCoordinator = dial
+}
+
+// SetMemberDialer replaces how the member connects to member id at addr.
+func (m *Member) SetMemberDialer(dial func(id, addr string) (MemberConn, error)) {
+	m.dialMember = func(p peer) (MemberConn, error) {
+		return dial(p.id, p.addr)
+	}
+}
+
+// Server returns the calls other members and the coordinator make to this
+// member, as made by caller.
+func (m *Member) Server(caller string) *Server {
+	return &Server{m: m, caller: caller}
+}
+
+// Init loads the member configuration and starts a member for the cluster
+// given by -kubeconfig.
+func Init() *Member {
+	flag.Parse()
+	config, err := LoadConfig()
+	if err != nil {
+		glog.Fatal("invalid configuration: ", err)
+	}
+
+	// use the current context in kubeconfig
+	restConfig, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		glog.Error(err.Error())
+	}
+
+	// create the clientset
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		glog.Error(err.Error())
+	}
+	glog.Info("clientset is created successfully.")
+
+	m, err := NewMember(config, clientset)
+	if err != nil {
+		glog.Fatal("invalid configuration: ", err)
+	}
+	glog.Infof("cluster %s advertises %s, coordinator is %s", m.clusterId, clusterAddr(m.clientAddress, m.clientPort), strings.Join(m.coordinatorAddrs, ","))
+	m.Start()
+	return m
+}
+
+// Start loads the nodes, running pods and tenants of the cluster.
+func (m *Member) Start() {
+	m.kube.initAllocatedResource()
+	m.kube.initNodes()
+	m.initShare()
+}
@@ -0,0 +1,668 @@
+package scheduler
+
+import (
+	"clock"
+	"crypto/tls"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"types"
+	"types/federationpb"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testCoordinator is a CoordinatorConn recording what the member reports.
+type testCoordinator struct {
+	lock       sync.Mutex
+	clusters   []types.Cluster
+	heartbeats []types.Cluster
+	uploads    []types.InterPod
+	released   []types.InterPod
+	confirmed  []types.Placement
+	uploadErr  error  // UploadPod fails with it after recording the pod
+	confirmErr error  // ConfirmPlacement fails with it
+	version    uint32 // RegisterCluster agrees on it
+	// reservation is what UploadPodGroup returns.
+	reservation types.GroupReservation
+}
+
+func (c *testCoordinator) RegisterCluster(cluster types.Cluster) (uint32, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.clusters = append(c.clusters, cluster)
+	return c.version, nil
+}
+
+func (c *testCoordinator) Heartbeat(cluster types.Cluster) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.heartbeats = append(c.heartbeats, cluster)
+	return nil
+}
+
+func (c *testCoordinator) DeregisterCluster(id string) error {
+	return nil
+}
+
+func (c *testCoordinator) UploadPod(pod types.InterPod) (float64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.uploads = append(c.uploads, pod)
+	return 0, c.uploadErr
+}
+
+func (c *testCoordinator) UploadPodGroup(group types.InterPodGroup) (types.GroupReservation, error) {
+	return c.reservation, nil
+}
+
+func (c *testCoordinator) ReleasePod(pod types.InterPod) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.released = append(c.released, pod)
+	return nil
+}
+
+func (c *testCoordinator) ConfirmPlacement(placement types.Placement) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.confirmed = append(c.confirmed, placement)
+	return c.confirmErr
+}
+
+func (c *testCoordinator) Reconcile(state types.MemberState) (types.Reconciliation, error) {
+	return types.Reconciliation{}, nil
+}
+
+func (c *testCoordinator) Close() error {
+	return nil
+}
+
+func (c *testCoordinator) heartbeatCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.heartbeats)
+}
+
+// localMember is a MemberConn calling another member in this process.
+type localMember struct {
+	srv *Server
+}
+
+func (c localMember) CreatePod(pod types.OutsourcePod) error {
+	var reply int
+	return c.srv.CreatePod(&pod, &reply)
+}
+
+func (c localMember) ReturnScheduleData(data types.ScheduleData) error {
+	var reply int
+	return c.srv.ReturnScheduleData(&data, &reply)
+}
+
+func (c localMember) ReleasePod(pod types.Pod) error {
+	var reply int
+	return c.srv.ReleasePod(&pod, &reply)
+}
+
+func (c localMember) Close() error {
+	return nil
+}
+
+var podsResource = v1.SchemeGroupVersion.WithResource("pods")
+
+// newTestClient returns a fake clientset holding objects that binds pods
+// like the API server does: the default reactor stores the Binding itself.
+func newTestClient(objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create := action.(k8stesting.CreateAction)
+		if create.GetSubresource() != "binding" {
+			return false, nil, nil
+		}
+		binding := create.GetObject().(*v1.Binding)
+		obj, err := client.Tracker().Get(podsResource, binding.Namespace, binding.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		pod := obj.(*v1.Pod).DeepCopy()
+		pod.Spec.NodeName = binding.Target.Name
+		pod.Status.Phase = v1.PodRunning
+		return true, binding, client.Tracker().Update(podsResource, pod, pod.Namespace)
+	})
+	return client
+}
+
+// newTestMember returns member id of the cluster client talks to, connected
+// to coordinator.
+func newTestMember(t *testing.T, id string, client *fake.Clientset, coordinator *testCoordinator) *Member {
+	t.Helper()
+	config := DefaultConfig()
+	config.ClusterId = id
+	m, err := NewMember(config, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetCoordinatorDialer(func(addr string) (CoordinatorConn, error) {
+		return coordinator, nil
+	})
+	m.kube.initNodes()
+	m.fixTotalResource()
+	m.Connect()
+	if !m.coordinator.Connected() {
+		t.Fatal("not connected to the coordinator")
+	}
+	return m
+}
+
+func testNode(name, cpu, memory string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+				v1.ResourcePods:   resource.MustParse("110"),
+			},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func testPod(namespace, name, schedulerName, cpu, memory string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1.PodSpec{
+			SchedulerName: schedulerName,
+			Containers: []v1.Container{{
+				Name:  "main",
+				Image: "busybox",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse(cpu),
+					v1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodPending},
+	}
+}
+
+func receivePod(t *testing.T, ch chan types.Pod) types.Pod {
+	t.Helper()
+	select {
+	case pod := <-ch:
+		return pod
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pod received")
+	}
+	return types.Pod{}
+}
+
+func TestWatchPodsQueuesPendingPods(t *testing.T) {
+	client := newTestClient(testNode("node1", "4", "8Gi"))
+	w := watch.NewFake()
+	client.PrependWatchReactor("pods", k8stesting.DefaultWatchReactor(w, nil))
+	m := newTestMember(t, "cluster1", client, &testCoordinator{})
+	go m.WatchPods()
+
+	m.kube.otherClustersPod["cluster2-q"] = peer{id: "cluster2"}
+	w.Add(testPod("tenant1", "ignored", "default-scheduler", "1", "1Gi"))
+	w.Add(testPod(outsourceNamespace, "cluster2-p", "federation-scheduler", "1", "1Gi"))
+	w.Add(testPod(outsourceNamespace, "cluster2-q", "binpack-scheduler", "1", "1Gi"))
+	w.Add(testPod("tenant1", "p", "federation-scheduler", "1", "1Gi"))
+
+	for _, name := range []string{"cluster2-p", "cluster2-q"} {
+		imported := receivePod(t, m.highPriorityCh)
+		if imported.Name != name || imported.Uid != outsourceNamespace {
+			t.Errorf("imported pod %s/%s, want %s/%s", imported.Uid, imported.Name, outsourceNamespace, name)
+		}
+	}
+	pending := receivePod(t, m.kube.pendingPodCh)
+	if pending.Name != "p" || pending.Uid != "tenant1" {
+		t.Errorf("pending pod %s/%s, want tenant1/p", pending.Uid, pending.Name)
+	}
+	if pending.Request().Get(types.ResourceCpu) != 1000 {
+		t.Errorf("pending pod requests %v, want 1 cpu", pending.Request())
+	}
+	if n := len(m.kube.pendingPodCh); n != 0 {
+		t.Errorf("%d more pods pending, the default-scheduler pod must be ignored", n)
+	}
+}
+
+func TestSchedulePodToNodeBinds(t *testing.T) {
+	pod := testPod("tenant1", "p", "federation-scheduler", "1", "1Gi")
+	client := newTestClient(testNode("node1", "4", "8Gi"), pod)
+	m := newTestMember(t, "cluster1", client, &testCoordinator{})
+	nodes := m.kube.getNodes()
+	if len(nodes) != 1 {
+		t.Fatalf("%d nodes available, want 1", len(nodes))
+	}
+
+	m.schedulePodToNode(toPod(pod), nodes[0])
+
+	bound, err := client.CoreV1().Pods("tenant1").Get("p", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound.Spec.NodeName != "node1" {
+		t.Errorf("pod bound to %q, want node1", bound.Spec.NodeName)
+	}
+	if got := m.kube.allocatedResource["node1"]; !got.Equal(toPod(pod).Request()) {
+		t.Errorf("node1 allocated %v, want %v", got, toPod(pod).Request())
+	}
+	select {
+	case data := <-m.executeDataQ:
+		if data.Name != "p" || data.Status != "running" {
+			t.Errorf("execute data %s %s, want p running", data.Name, data.Status)
+		}
+	default:
+		t.Error("no execute data recorded")
+	}
+}
+
+func TestOutsourcePodCreatesItAtDestination(t *testing.T) {
+	pod := testPod("tenant1", "p", "federation-scheduler", "1", "1Gi")
+	source := newTestMember(t, "cluster1", newTestClient(testNode("node1", "1", "1Gi"), pod), &testCoordinator{})
+	destClient := newTestClient(testNode("node1", "4", "8Gi"))
+	dest := newTestMember(t, "cluster2", destClient, &testCoordinator{})
+	source.SetMemberDialer(func(id, addr string) (MemberConn, error) {
+		if id != dest.clusterId {
+			return nil, errors.New("unknown member " + id)
+		}
+		return localMember{dest.Server(source.clusterId)}, nil
+	})
+	source.podInfo["p"] = *pod
+	source.awaiting["p"] = awaitingPod{}
+
+	result := types.ScheduleResult{Pod: toPod(pod), DestClusterId: "cluster3"}
+	if err := source.receiveResult(result); err == nil {
+		t.Fatal("outsourced to an unknown member")
+	}
+	if _, ok := source.awaiting["p"]; !ok {
+		t.Fatal("pod no longer awaits a result after outsourcing failed")
+	}
+
+	result.DestClusterId = dest.clusterId
+	if err := source.receiveResult(result); err != nil {
+		t.Fatal(err)
+	}
+	created, err := destClient.CoreV1().Pods(outsourceNamespace).Get("cluster1-p", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Spec.SchedulerName != "federation-scheduler" {
+		t.Errorf("created pod has scheduler %q", created.Spec.SchedulerName)
+	}
+	if from, ok := dest.kube.sourceOf("cluster1-p"); !ok || from.id != source.clusterId {
+		t.Errorf("source of cluster1-p is %q, want %s", from.id, source.clusterId)
+	}
+	if to := source.outsourcedTo["p"]; to != dest.clusterId {
+		t.Errorf("p outsourced to %q, want %s", to, dest.clusterId)
+	}
+	if _, ok := source.awaiting["p"]; ok {
+		t.Error("p still awaits a result")
+	}
+}
+
+func TestFinishedPodIsReleased(t *testing.T) {
+	coordinator := &testCoordinator{}
+	m := newTestMember(t, "cluster1", newTestClient(testNode("node1", "4", "8Gi")), coordinator)
+	pod := toPod(testPod("tenant1", "p", "federation-scheduler", "1", "1Gi"))
+	pod.NodeName = "node1"
+	m.kube.allocatedResource["node1"] = pod.Request()
+	if share := m.fixUserShare(pod, 0); share != 0.25 {
+		t.Fatalf("share of tenant1 is %v, want 0.25", share)
+	}
+	go m.UpdateAllocatedResource()
+
+	m.kube.deletedPodCh <- pod
+	finished := receivePod(t, m.finishedPodCh)
+	if !m.kube.allocatedResource["node1"].IsZero() {
+		t.Errorf("node1 still allocated %v", m.kube.allocatedResource["node1"])
+	}
+	if !m.releaseUserPod(finished) {
+		t.Fatal("finished pod was not charged to its tenant")
+	}
+	if m.releaseUserPod(finished) {
+		t.Error("finished pod released twice")
+	}
+	if !m.usersAllocatedRes["tenant1"].IsZero() || m.getUserShare("tenant1") != 0 {
+		t.Errorf("tenant1 still allocated %v", m.usersAllocatedRes["tenant1"])
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for coordinator.heartbeatCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no heartbeat after the pod finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStepSchedulesPendingPod(t *testing.T) {
+	tenant := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant1"}}
+	client := newTestClient(testNode("node1", "4", "8Gi"), tenant)
+	m := newTestMember(t, "cluster1", client, &testCoordinator{})
+	m.eventsConfig.Dir = t.TempDir()
+	clk := clock.NewVirtual(time.Unix(1000, 0))
+	m.SetClock(clk)
+	if err := m.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	pod := testPod("tenant1", "p", "federation-scheduler", "1", "1Gi")
+	pod.CreationTimestamp = metav1.NewTime(clk.Now())
+	if _, err := client.CoreV1().Pods("tenant1").Create(pod); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(5 * time.Second)
+	m.Step()
+
+	bound, err := client.CoreV1().Pods("tenant1").Get("p", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound.Spec.NodeName != "node1" {
+		t.Errorf("pod bound to %q after a step, want node1", bound.Spec.NodeName)
+	}
+	if got := m.kube.allocatedResource["node1"]; !got.Equal(toPod(pod).Request()) {
+		t.Errorf("node1 allocated %v, want %v", got, toPod(pod).Request())
+	}
+}
+
+func TestFailedUploadKeepsPod(t *testing.T) {
+	pod := testPod("tenant1", "p", "federation-scheduler", "2", "1Gi")
+	client := newTestClient(testNode("node1", "1", "8Gi"), pod)
+	coordinator := &testCoordinator{uploadErr: errors.New("reply lost")}
+	m := newTestMember(t, "cluster1", client, coordinator)
+	m.local = false
+	w := &waitingPod{user: &types.User{Uid: "tenant1"}, pod: toPod(pod)}
+
+	if _, ok := m.schedulePod(w); ok {
+		t.Fatal("pod scheduled although its upload failed")
+	}
+	if _, err := client.CoreV1().Pods("tenant1").Get("p", metav1.GetOptions{}); err != nil {
+		t.Fatalf("pod deleted after a failed upload: %v", err)
+	}
+
+	coordinator.lock.Lock()
+	coordinator.uploadErr = nil
+	coordinator.lock.Unlock()
+	if _, ok := m.schedulePod(w); !ok {
+		t.Fatal("pod not outsourced once its upload succeeded")
+	}
+	if _, err := client.CoreV1().Pods("tenant1").Get("p", metav1.GetOptions{}); err == nil {
+		t.Error("pod kept after it was uploaded")
+	}
+	if n := len(coordinator.uploads); n != 2 {
+		t.Errorf("%d uploads, want 2", n)
+	}
+}
+
+func TestGroupPodsRejectedByDestinationAreKept(t *testing.T) {
+	pods := []*v1.Pod{
+		testPod("tenant1", "p0", "federation-scheduler", "2", "1Gi"),
+		testPod("tenant1", "p1", "federation-scheduler", "2", "1Gi"),
+	}
+	coordinator := &testCoordinator{}
+	client := newTestClient(testNode("node1", "1", "8Gi"), pods[0], pods[1])
+	source := newTestMember(t, "cluster1", client, coordinator)
+	source.local = false
+	dest := newTestMember(t, "cluster2", newTestClient(testNode("node1", "4", "8Gi")), &testCoordinator{})
+	source.SetMemberDialer(func(id, addr string) (MemberConn, error) {
+		if id != dest.clusterId {
+			return nil, errors.New("unknown member " + id)
+		}
+		return localMember{dest.Server(source.clusterId)}, nil
+	})
+	group := types.PodGroup{Name: "g", Uid: "tenant1", MinMember: 2}
+	for _, pod := range pods {
+		source.podInfo[pod.Name] = *pod
+		group.Pods = append(group.Pods, toPod(pod))
+	}
+	coordinator.reservation = types.GroupReservation{Results: []types.ScheduleResult{
+		{Pod: group.Pods[0], DestClusterId: dest.clusterId},
+		{Pod: group.Pods[1], DestClusterId: "cluster3"},
+	}}
+
+	w := &waitingPod{user: &types.User{Uid: "tenant1"}, pod: types.Pod{Uid: "tenant1", Group: "g"}, group: &group}
+	if _, ok := source.scheduleGroup(w); !ok {
+		t.Fatal("group not outsourced")
+	}
+	if _, err := client.CoreV1().Pods("tenant1").Get("p0", metav1.GetOptions{}); err == nil {
+		t.Error("p0 kept after its destination created it")
+	}
+	if _, err := client.CoreV1().Pods("tenant1").Get("p1", metav1.GetOptions{}); err != nil {
+		t.Fatalf("p1 deleted although its destination did not create it: %v", err)
+	}
+	awaited, ok := source.awaiting["p1"]
+	if !ok || !awaited.kept {
+		t.Fatal("p1 does not await its result again")
+	}
+
+	if err := source.receiveResult(types.ScheduleResult{Pod: group.Pods[1], DestClusterId: dest.clusterId}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Pods("tenant1").Get("p1", metav1.GetOptions{}); err == nil {
+		t.Error("p1 kept after its destination created it")
+	}
+}
+
+func TestHeartbeatWhileBinding(t *testing.T) {
+	pod := testPod("tenant1", "p", "federation-scheduler", "1", "1Gi")
+	m := newTestMember(t, "cluster1", newTestClient(testNode("node1", "4", "8Gi"), pod), &testCoordinator{})
+	node := m.kube.getNodes()[0]
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.Heartbeat()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		m.kube.allocate(node.Name, toPod(pod).Request())
+		m.kube.free(node.Name, toPod(pod).Request())
+	}
+	m.schedulePodToNode(toPod(pod), node)
+	<-done
+	if got := m.kube.allocation()[node.Name]; !got.Equal(toPod(pod).Request()) {
+		t.Errorf("node1 allocated %v, want %v", got, toPod(pod).Request())
+	}
+}
+
+func TestHeartbeatReportsTopNodePerDimension(t *testing.T) {
+	gpuNode := testNode("gpu", "1", "1Gi")
+	gpuNode.Status.Allocatable["nvidia.com/gpu"] = resource.MustParse("4")
+	memoryNode := testNode("memory", "2", "16Gi")
+	memoryNode.Status.Allocatable[v1.ResourcePods] = resource.MustParse("200")
+	coordinator := &testCoordinator{}
+	m := newTestMember(t, "cluster1", newTestClient(
+		testNode("cpu", "8", "2Gi"), memoryNode, testNode("both", "4", "4Gi"), gpuNode,
+	), coordinator)
+
+	m.Heartbeat()
+	coordinator.lock.Lock()
+	idle := coordinator.heartbeats[len(coordinator.heartbeats)-1].IdleNodes
+	coordinator.lock.Unlock()
+	var names []string
+	for _, node := range idle {
+		names = append(names, node.Name)
+	}
+	// cpu, memory, nvidia.com/gpu and pods, memory leads pods as well.
+	want := []string{"cpu", "memory", "gpu"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("idle nodes %v, want %v", names, want)
+	}
+}
+
+func TestRegisterClusterSendsWeight(t *testing.T) {
+	coordinator := &testCoordinator{}
+	config := DefaultConfig()
+	config.ClusterId = "cluster1"
+	config.Weight = 2
+	m, err := NewMember(config, newTestClient(testNode("node1", "4", "8Gi")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.registerCluster(coordinator); err != nil {
+		t.Fatal(err)
+	}
+	if got := coordinator.clusters[0]; got.Weight != 2 || got.Priority != 0 {
+		t.Errorf("registered weight %v and priority %v, want 2 and 0", got.Weight, got.Priority)
+	}
+}
+
+func TestCreatePodNeedsConfirmedPlacement(t *testing.T) {
+	coordinator := &testCoordinator{confirmErr: errors.New("not placed here"), version: federationpb.ProtocolVersion}
+	client := newTestClient(testNode("node1", "4", "8Gi"))
+	dest := newTestMember(t, "cluster2", client, coordinator)
+	dest.tlsConfig = &tls.Config{}
+	pod := testPod("tenant1", "p", "federation-scheduler", "1", "1Gi")
+	outsourcePod := types.OutsourcePod{Pod: *pod, ClusterId: "cluster1", PodJson: encodePod(*pod)}
+	var reply int
+
+	if err := dest.Server("cluster1").CreatePod(&outsourcePod, &reply); err == nil {
+		t.Fatal("created a pod the coordinator did not place here")
+	}
+	if _, err := client.CoreV1().Pods(outsourceNamespace).Get("cluster1-p", metav1.GetOptions{}); err == nil {
+		t.Fatal("unconfirmed pod was created")
+	}
+	want := types.Placement{
+		InterPod:      types.InterPod{Pod: toPod(pod), ClusterId: "cluster1"},
+		DestClusterId: "cluster2",
+	}
+	if got := coordinator.confirmed[0]; got.Uid != want.Uid || got.Name != want.Name || got.ClusterId != want.ClusterId || got.DestClusterId != want.DestClusterId {
+		t.Errorf("confirmed %s/%s of %s on %s, want %s/%s of %s on %s", got.Uid, got.Name, got.ClusterId, got.DestClusterId,
+			want.Uid, want.Name, want.ClusterId, want.DestClusterId)
+	}
+
+	coordinator.confirmErr = nil
+	if err := dest.Server("cluster1").CreatePod(&outsourcePod, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Pods(outsourceNamespace).Get("cluster1-p", metav1.GetOptions{}); err != nil {
+		t.Fatalf("confirmed pod was not created: %v", err)
+	}
+}
+
+func TestCreatePodUnconfirmed(t *testing.T) {
+	refused := errors.New("not placed here")
+	tests := []struct {
+		name       string
+		tls        bool
+		version    uint32
+		confirmErr error
+	}{
+		{"without TLS", false, federationpb.ProtocolVersion, refused},
+		{"coordinator without ConfirmPlacement", true, federationpb.ConfirmPlacementVersion - 1, refused},
+		{"coordinator not reached", true, federationpb.ProtocolVersion, io.EOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			coordinator := &testCoordinator{confirmErr: test.confirmErr, version: test.version}
+			client := newTestClient(testNode("node1", "4", "8Gi"))
+			dest := newTestMember(t, "cluster2", client, coordinator)
+			if test.tls {
+				dest.tlsConfig = &tls.Config{}
+			}
+			pod := testPod("tenant1", "p", "federation-scheduler", "1", "1Gi")
+			outsourcePod := types.OutsourcePod{Pod: *pod, ClusterId: "cluster1", PodJson: encodePod(*pod)}
+			var reply int
+
+			if err := dest.Server("cluster1").CreatePod(&outsourcePod, &reply); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.CoreV1().Pods(outsourceNamespace).Get("cluster1-p", metav1.GetOptions{}); err != nil {
+				t.Fatalf("pod was not created: %v", err)
+			}
+		})
+	}
+}
+
+func TestPodsQueuedBeforeTheirNamespaceAreScheduled(t *testing.T) {
+	client := newTestClient(testNode("node1", "4", "8Gi"))
+	m := newTestMember(t, "cluster1", client, &testCoordinator{})
+	m.eventsConfig.Dir = t.TempDir()
+	clk := clock.NewVirtual(time.Unix(1000, 0))
+	m.SetClock(clk)
+	if err := m.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// tenant1's namespace event has not arrived, another tenant's does
+	// while its second pod is queued.
+	for _, name := range []string{"p1", "p2"} {
+		pod := testPod("tenant1", name, "federation-scheduler", "1", "1Gi")
+		pod.CreationTimestamp = metav1.NewTime(clk.Now())
+		if _, err := client.CoreV1().Pods("tenant1").Create(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Step()
+	tenant := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant2"}}
+	if _, err := client.CoreV1().Namespaces().Create(tenant); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Second)
+	m.Step()
+
+	for _, name := range []string{"p1", "p2"} {
+		bound, err := client.CoreV1().Pods("tenant1").Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bound.Spec.NodeName != "node1" {
+			t.Errorf("%s bound to %q, want node1", name, bound.Spec.NodeName)
+		}
+	}
+}
+
+func TestParkedPodDoesNotBlockOtherTenants(t *testing.T) {
+	client := newTestClient(testNode("node1", "1", "8Gi"))
+	m := newTestMember(t, "cluster1", client, &testCoordinator{})
+	m.eventsConfig.Dir = t.TempDir()
+	clk := clock.NewVirtual(time.Unix(1000, 0))
+	m.SetClock(clk)
+	if err := m.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	big := testPod("tenant1", "big", "federation-scheduler", "2", "1Gi")
+	small := testPod("tenant2", "small", "federation-scheduler", "1", "1Gi")
+	for _, pod := range []*v1.Pod{big, small} {
+		pod.CreationTimestamp = metav1.NewTime(clk.Now())
+		if _, err := client.CoreV1().Pods(pod.Namespace).Create(pod); err != nil {
+			t.Fatal(err)
+		}
+		m.Step()
+	}
+	for i := 0; i < 3; i++ {
+		clk.Advance(time.Second)
+		m.Step()
+	}
+
+	bound, err := client.CoreV1().Pods("tenant2").Get("small", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound.Spec.NodeName != "node1" {
+		t.Errorf("small bound to %q behind a pod that does not fit, want node1", bound.Spec.NodeName)
+	}
+	w, ok := m.parked["tenant1"]
+	if !ok || w.pod.Name != "big" {
+		t.Fatal("big is not parked")
+	}
+	if w.backoff <= parkMin {
+		t.Errorf("big was tried again with backoff %v, want more than %v", w.backoff, parkMin)
+	}
+}
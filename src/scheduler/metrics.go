@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"net/http"
+	"sort"
+	"types"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are exported to Prometheus by the member's /metrics. Gauges are
+// set where the scheduler changes its state, so scrapes never touch it.
+var (
+	tenantShareGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_tenant_dominant_share",
+		Help: "Weighted dominant share of a tenant.",
+	}, []string{"tenant"})
+	tenantAllocatedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_tenant_allocated_resource",
+		Help: "Resources held by a tenant's pods, here or on other clusters.",
+	}, []string{"tenant", "resource"})
+	tenantQueueGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "federation_tenant_queue_depth",
+		Help: "Pods of a tenant waiting in usersPodsQ.",
+	}, []string{"tenant"})
+	outsourcedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "federation_pods_outsourced_total",
+		Help: "Pods uploaded to the coordinator to run on another cluster.",
+	})
+	importedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "federation_pods_imported_total",
+		Help: "Pods of other clusters created here, by source cluster.",
+	}, []string{"source"})
+	scheduleLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "federation_member_schedule_latency_seconds",
+		Help:    "Time from creation of a pod to it being bound or outsourced.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{"placement"})
+	rpcErrorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "federation_member_rpc_errors_total",
+		Help: "Failed calls to the coordinator and other members, by method.",
+	}, []string{"method"})
+	coordinatorConnectedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "federation_member_coordinator_connected",
+		Help: "1 while the coordinator is reached, 0 while reconnecting to it.",
+	})
+	coordinatorReconnectCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "federation_member_coordinator_reconnects_total",
+		Help: "Attempts to reach the coordinator again after losing it.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(tenantShareGauge, tenantAllocatedGauge, tenantQueueGauge,
+		outsourcedCounter, importedCounter, scheduleLatency, rpcErrorCounter,
+		coordinatorConnectedGauge, coordinatorReconnectCounter)
+}
+
+// ServeMetrics serves Prometheus on metricsAddress, a plain HTTP listener
+// apart from the mutual TLS of the federation port.
+func (m *Member) ServeMetrics() {
+	if m.metricsAddress == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	glog.Fatal(http.ListenAndServe(m.metricsAddress, mux))
+}
+
+// recordTenant exports the share and allocation of a tenant.
+func (m *Member) recordTenant(uid string) {
+	allocated := m.usersAllocatedRes[uid].Dims()
+	tenantShareGauge.WithLabelValues(uid).Set(m.usersShare[uid])
+	tenantAllocatedGauge.DeletePartialMatch(prometheus.Labels{"tenant": uid})
+	for name, v := range allocated {
+		tenantAllocatedGauge.WithLabelValues(uid, name).Set(float64(v))
+	}
+	m.tenantsLock.Lock()
+	t := m.tenants[uid]
+	t.Tenant, t.Share, t.Weight, t.Allocated = uid, m.usersShare[uid], m.getUserWeight(uid), allocated
+	m.tenants[uid] = t
+	m.tenantsLock.Unlock()
+}
+
+// forgetTenant drops the series of a deleted tenant.
+func (m *Member) forgetTenant(uid string) {
+	labels := prometheus.Labels{"tenant": uid}
+	tenantShareGauge.DeletePartialMatch(labels)
+	tenantAllocatedGauge.DeletePartialMatch(labels)
+	tenantQueueGauge.DeletePartialMatch(labels)
+	m.tenantsLock.Lock()
+	delete(m.tenants, uid)
+	m.tenantsLock.Unlock()
+}
+
+func (m *Member) recordQueue(uid string, podsQ chan types.Pod) {
+	tenantQueueGauge.WithLabelValues(uid).Set(float64(len(podsQ)))
+	m.tenantsLock.Lock()
+	t := m.tenants[uid]
+	t.Tenant, t.PendingPods = uid, len(podsQ)
+	m.tenants[uid] = t
+	m.tenantsLock.Unlock()
+}
+
+// tenantStatuses returns the tenants sorted by name.
+func (m *Member) tenantStatuses() []types.TenantStatus {
+	m.tenantsLock.Lock()
+	defer m.tenantsLock.Unlock()
+	statuses := make([]types.TenantStatus, 0, len(m.tenants))
+	for _, t := range m.tenants {
+		statuses = append(statuses, t)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Tenant < statuses[j].Tenant })
+	return statuses
+}
+
+// recordScheduled observes the latency of a pod placed as placement,
+// "local" or "outsourced".
+func (m *Member) recordScheduled(pod types.Pod, placement string) {
+	created, ok := m.getPodInfo(pod.Name)
+	if !ok {
+		return
+	}
+	scheduleLatency.WithLabelValues(placement).Observe(m.clk.Now().Sub(created.CreationTimestamp.Time).Seconds())
+}
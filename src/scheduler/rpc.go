@@ -1,33 +1,117 @@
 package scheduler
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"net/rpc"
+	"sort"
+	"strings"
+	"time"
 	"types"
+	"types/federationpb"
 
 	"github.com/golang/glog"
+	"google.golang.org/grpc"
 )
 
 const (
-	serverAddress = "localhost"
-	serverPort    = "1234"
-	clusterId     = "cluster1"
-	clientAddress = "localhost"
-	clientPort    = "4321"
-	local         = true
+	// reconcileInterval is how often the coordinator's ledgers are
+	// reconciled with this cluster, awaitGrace how long an uploaded pod
+	// waits for its result before it is reported as awaiting one.
+	reconcileInterval = time.Minute
+	awaitGrace        = time.Minute
 )
 
-var (
-	client *rpc.Client
-)
+type awaitingPod struct {
+	pod   types.Pod
+	since time.Time
+	kept  bool // the pod is still here, it is deleted once outsourced
+}
+
+// CoordinatorConn is the member's connection to the coordinator, in gRPC
+// or, for coordinators that predate it, net/rpc.
+type CoordinatorConn interface {
+	// RegisterCluster returns the protocol version agreed on.
+	RegisterCluster(cluster types.Cluster) (uint32, error)
+	Heartbeat(cluster types.Cluster) error
+	DeregisterCluster(id string) error
+	UploadPod(pod types.InterPod) (float64, error)
+	UploadPodGroup(group types.InterPodGroup) (types.GroupReservation, error)
+	ReleasePod(pod types.InterPod) error
+	ConfirmPlacement(placement types.Placement) error
+	Reconcile(state types.MemberState) (types.Reconciliation, error)
+	Close() error
+}
+
+// MemberConn is a connection to another member.
+type MemberConn interface {
+	CreatePod(pod types.OutsourcePod) error
+	ReturnScheduleData(data types.ScheduleData) error
+	ReleasePod(pod types.Pod) error
+	Close() error
+}
+
+// peer is another member as seen from here.
+type peer struct {
+	id      string // cluster id its certificate is issued to
+	addr    string
+	version uint32 // protocol version it is called with
+}
+
+// dialPeer connects to a member with the protocol of version, it is the
+// member's dialMember unless SetMemberDialer replaces it.
+func (m *Member) dialPeer(p peer) (MemberConn, error) {
+	config := federationpb.WithServerName(m.tlsConfig, p.id)
+	if p.version > 0 {
+		return dialMemberGrpc(p.addr, config, m.rpcTimeout)
+	}
+	client, err := federationpb.DialRPC(p.addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return rpcMember{client}, nil
+}
+
+// Server serves the calls of the coordinator or of another member, whose
+// certificate is issued to caller.
+type Server struct {
+	m      *Member
+	caller string
+}
+
+// newRpcServer is the net/rpc server of one connection.
+func (m *Member) newRpcServer(caller string) *rpc.Server {
+	srv := rpc.NewServer()
+	srv.Register(m.Server(caller))
+	return srv
+}
 
-type Server int
+// authorize checks that the caller is identity.
+func (t *Server) authorize(identity string) error {
+	if t.m.tlsConfig == nil || t.caller == identity {
+		return nil
+	}
+	glog.Warningf("%s is denied to act as %s", t.caller, identity)
+	return federationpb.ErrPermissionDenied
+}
 
+// CreatePod is called by the source cluster of a pod the coordinator placed
+// here. Pods of other clusters are only created once the coordinator has
+// confirmed it placed them here.
 func (t *Server) CreatePod(outsourcePod *types.OutsourcePod, reply *int) error {
-	err := createPod(*outsourcePod)
+	if err := t.authorize(outsourcePod.ClusterId); err != nil {
+		return err
+	}
+	if outsourcePod.ClusterId != t.m.clusterId && t.m.confirmsPlacements() {
+		if err := t.m.confirmPlacement(*outsourcePod); err != nil {
+			return err
+		}
+	}
+	err := t.m.createPod(*outsourcePod)
 	if err == nil {
+		importedCounter.WithLabelValues(outsourcePod.ClusterId).Inc()
 		glog.Info("CreatePod:", outsourcePod.Pod.Name)
 	} else {
 		glog.Error(err)
@@ -36,140 +120,483 @@ func (t *Server) CreatePod(outsourcePod *types.OutsourcePod, reply *int) error {
 	return err
 }
 
+// confirmsPlacements tells whether imported pods are confirmed with the
+// coordinator first. Only members authenticated by TLS do so, with a
+// coordinator that answers ConfirmPlacement.
+func (m *Member) confirmsPlacements() bool {
+	return m.tlsConfig != nil && m.negotiatedVersion() >= federationpb.ConfirmPlacementVersion
+}
+
+// confirmPlacement asks the coordinator whether it placed pod here. A
+// coordinator that cannot be reached does not hold the pod up.
+func (m *Member) confirmPlacement(pod types.OutsourcePod) error {
+	placement := types.Placement{
+		InterPod:      types.InterPod{Pod: toPod(&pod.Pod), ClusterId: pod.ClusterId},
+		DestClusterId: m.clusterId,
+	}
+	err := m.coordinator.ConfirmPlacement(placement)
+	if err == errDisconnected || federationpb.Unreachable(err) {
+		glog.Warningf("Create %s of %s unconfirmed: %v", pod.Pod.Name, pod.ClusterId, err)
+		return nil
+	}
+	if err != nil {
+		rpcErrorCounter.WithLabelValues("Coordinator.ConfirmPlacement").Inc()
+		glog.Warningf("Refuse to create %s of %s: %v", pod.Pod.Name, pod.ClusterId, err)
+	}
+	return err
+}
+
 func (t *Server) ReturnScheduleResult(result *types.ScheduleResult, reply *int) error {
-	cli, err := rpc.DialHTTP("tcp", result.DestIp+":"+clientPort)
+	if err := t.authorize(t.m.coordinatorIdentity); err != nil {
+		return err
+	}
+	return t.m.receiveResult(*result)
+}
+
+// receiveResult outsources an uploaded pod as result says. Results for pods
+// that no longer await one, such as those Reconcile sends again, are
+// dropped. A pod that could not be outsourced awaits its result again.
+func (m *Member) receiveResult(result types.ScheduleResult) error {
+	m.outsourcedToLock.Lock()
+	awaited, ok := m.awaiting[result.Pod.Name]
+	delete(m.awaiting, result.Pod.Name)
+	m.outsourcedToLock.Unlock()
+	if !ok {
+		glog.Warningf("Drop result for %s, it awaits none.", result.Pod.Name)
+		return nil
+	}
+	err := m.outsourcePodTo(result)
+	if err != nil {
+		m.outsourcedToLock.Lock()
+		m.awaiting[result.Pod.Name] = awaited
+		m.outsourcedToLock.Unlock()
+	} else if awaited.kept {
+		m.kube.deletePodByName(awaited.pod.Name, awaited.pod.Uid)
+	}
+	return err
+}
+
+// resultReceived tells whether the pod named name was outsourced after a
+// result of the coordinator came in.
+func (m *Member) resultReceived(name string) bool {
+	m.outsourcedToLock.Lock()
+	defer m.outsourcedToLock.Unlock()
+	_, ok := m.outsourcedTo[name]
+	return ok
+}
+
+// outsourcePodTo has the destination cluster of result create the pod. With
+// TLS the destination must prove it is the cluster the coordinator chose.
+func (m *Member) outsourcePodTo(result types.ScheduleResult) error {
+	if m.tlsConfig != nil && result.DestClusterId == "" {
+		err := fmt.Errorf("no destination cluster for %s", result.Pod.Name)
+		glog.Error(err)
+		return err
+	}
+	dest := peer{result.DestClusterId, clusterAddr(result.DestIp, result.DestPort), result.DestVersion}
+	conn, err := m.dialMember(dest)
 	if err == nil {
 		glog.Info("UploadResult:", result)
 	} else {
+		rpcErrorCounter.WithLabelValues("Member.CreatePod").Inc()
 		glog.Error(err)
+		return err
 	}
+	defer conn.Close()
 	// create a outsourcePod
-	var reply2 int
-	var requestsMilliCpu, requestsMemory int64
-	pod := podInfo[result.Pod.Name]
-	for _, ctn := range pod.Spec.Containers {
-		requestsMilliCpu += ctn.Resources.Requests.Cpu().MilliValue()
-		requestsMemory += ctn.Resources.Requests.Memory().Value() / 1024 / 1024
-	}
+	pod, _ := m.getPodInfo(result.Pod.Name)
 	outsourcePod := types.OutsourcePod{
-		Pod:       podInfo[result.Pod.Name],
-		ClusterId: clusterId,
-		SourceIP:  clientAddress,
-		Resource: types.Resource{
-			MilliCpu: requestsMilliCpu,
-			Memory:   requestsMemory,
-		},
-	}
-	err = cli.Call("Server.CreatePod", &outsourcePod, &reply2)
+		Pod:           pod,
+		ClusterId:     m.clusterId,
+		SourceIP:      m.clientAddress,
+		SourcePort:    m.clientPort,
+		PodJson:       encodePod(pod),
+		Resource:      types.NewPodRequest(pod.Spec),
+		SourceVersion: m.negotiatedVersion(),
+	}
+	m.outsourcedToLock.Lock()
+	m.outsourcedTo[result.Pod.Name] = result.DestClusterId
+	m.outsourcedToLock.Unlock()
+	err = conn.CreatePod(outsourcePod)
 	if err == nil {
 		glog.Info("Server.CreatePod:", result.Pod)
 	} else {
+		rpcErrorCounter.WithLabelValues("Member.CreatePod").Inc()
 		glog.Error(err)
+		m.outsourcedToLock.Lock()
+		delete(m.outsourcedTo, result.Pod.Name)
+		m.outsourcedToLock.Unlock()
 	}
 	return err
 }
 
+// authorizeDest checks that the caller runs our pod podName.
+func (t *Server) authorizeDest(podName string) error {
+	t.m.outsourcedToLock.Lock()
+	dest, ok := t.m.outsourcedTo[podName]
+	t.m.outsourcedToLock.Unlock()
+	if !ok {
+		dest = t.m.clusterId
+	}
+	return t.authorize(dest)
+}
+
+// ReturnScheduleData is called by the cluster running one of our outsourced
+// pods, which it names after us.
 func (t *Server) ReturnScheduleData(result *types.ScheduleData, reply *int) error {
-	scheduleDataQ <- *result
+	if err := t.authorizeDest(strings.TrimPrefix(result.Name, t.m.clusterId+"-")); err != nil {
+		return err
+	}
+	t.m.queueScheduleData(*result)
 	*reply = 1
 	return nil
 }
 
-func RpcInit() {
-	// connect to coordinator
-	var err error
-	client, err = rpc.DialHTTP("tcp", serverAddress+":"+serverPort)
-	if err != nil {
-		glog.Info(err)
+// ReleasePod is called by the cluster running one of our outsourced pods once
+// it no longer holds resources there.
+func (t *Server) ReleasePod(pod *types.Pod, reply *int) error {
+	if err := t.authorizeDest(pod.Name); err != nil {
+		return err
+	}
+	t.m.outsourcedToLock.Lock()
+	delete(t.m.outsourcedTo, pod.Name)
+	t.m.outsourcedToLock.Unlock()
+	glog.Info("ReleasePod:", pod.Name)
+	t.m.send(t.m.finishedPodCh, *pod)
+	*reply = 1
+	return nil
+}
+
+// TenantShares is called by operators.
+func (t *Server) TenantShares(args *int, reply *[]types.TenantStatus) error {
+	if err := t.authorize(t.m.operatorIdentity); err != nil {
+		return err
 	}
-	RegisterCluster()
-	Heartbeat()
+	*reply = t.m.tenantStatuses()
+	return nil
+}
 
-	// create server
-	rpc.Register(new(Server))
-	rpc.HandleHTTP()
-	listener, err := net.Listen("tcp", ":"+clientPort)
+// RpcInit connects to the coordinator and serves the calls of the
+// federation on listenAddress.
+func (m *Member) RpcInit() {
+	m.Connect()
+
+	if m.tlsConfig == nil {
+		glog.Warning("TLS is disabled, anyone reaching ", m.listenAddress, " can create pods here.")
+	}
+	// create server, gRPC and net/rpc share the port.
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, federationpb.RPCHandler(m.newRpcServer))
+	grpcServer := grpc.NewServer()
+	federationpb.RegisterMemberServer(grpcServer, &memberServer{m: m})
+	listener, err := net.Listen("tcp", m.listenAddress)
 	if err != nil {
-		fmt.Println(err)
+		glog.Fatal(err)
 	}
-	go http.Serve(listener, nil)
+	go federationpb.Serve(listener, federationpb.Handler(grpcServer, mux), m.tlsConfig)
 }
 
-func RegisterCluster() {
-	nodes := getNodes()
-	var totalResource types.Resource
-	for _, node := range nodes {
-		totalResource.MilliCpu += node.MilliCpu
-		totalResource.Memory += node.Memory
+// Connect connects to the coordinator, or whichever of its replicas leads,
+// and registers whenever the connection is made again.
+func (m *Member) Connect() {
+	m.coordinator = newFailoverConn(m.coordinatorAddrs, m.dialCoordinator, m.registerCluster, func() {
+		m.Heartbeat()
+		m.Reconcile()
+	}, m.clk)
+	m.coordinator.Connect()
+}
+
+// dialReplica connects to the coordinator replica at addr, it is the
+// member's dialCoordinator unless SetCoordinatorDialer replaces it.
+func (m *Member) dialReplica(addr string) (CoordinatorConn, error) {
+	config := federationpb.WithServerName(m.tlsConfig, m.coordinatorIdentity)
+	if m.protocol == "netrpc" {
+		return dialCoordinatorRpc(addr, config)
 	}
-	cluster := types.Cluster{Id: clusterId, Ip: clientAddress, TotalResource: totalResource}
-	var reply int
-	err := client.Call("Server.RegisterCluster", cluster, &reply)
+	return dialCoordinatorGrpc(addr, config, m.rpcTimeout)
+}
+
+// registerCluster registers this cluster over conn, coordinator does so on
+// every new connection.
+func (m *Member) registerCluster(conn CoordinatorConn) error {
+	totalResource := m.getTotalResource()
+	cluster := types.Cluster{Id: m.clusterId, Weight: m.clusterWeight, Ip: m.clientAddress, Port: m.clientPort, TotalResource: totalResource, Nodes: m.kube.getNodes()}
+	version, err := conn.RegisterCluster(cluster)
 	if err != nil {
+		rpcErrorCounter.WithLabelValues("Coordinator.RegisterCluster").Inc()
 		glog.Info(err)
+		return err
 	}
+	m.outsourcedToLock.Lock()
+	m.protocolVersion = version
+	m.outsourcedToLock.Unlock()
+	glog.Infof("registered with protocol version %d", version)
+	return nil
+}
+
+// negotiatedVersion returns the protocol version agreed with the
+// coordinator at the last registration.
+func (m *Member) negotiatedVersion() uint32 {
+	m.outsourcedToLock.Lock()
+	defer m.outsourcedToLock.Unlock()
+	return m.protocolVersion
 }
 
-func Heartbeat() {
-	nodes := getNodes()
-	var mostCpuNode, mostMemoryNode types.InterNode
-	var mostCpu, mostMemory int64
-	mostCpu = 0
-	mostMemory = 0
-	for _, node := range nodes {
-		allocatedRes := allocatedResource[node.Name]
-		idleCpu := node.MilliCpu - allocatedRes.MilliCpu
-		idleMemory := node.Memory - allocatedRes.Memory
-		if idleCpu > mostCpu {
-			mostCpuNode.Node = node
-			mostCpuNode.IdleResource.Memory = idleMemory
-			mostCpuNode.IdleResource.MilliCpu = idleCpu
-			mostCpu = idleCpu
+// KeepAlive sends periodic heartbeats so that an idle cluster is not expired
+// by the coordinator, and reconciles the coordinator's ledgers.
+func (m *Member) KeepAlive() {
+	heartbeats := m.clk.Tick(m.heartbeatInterval)
+	reconciles := m.clk.Tick(reconcileInterval)
+	for {
+		select {
+		case <-heartbeats:
+			m.Heartbeat()
+		case <-reconciles:
+			m.Reconcile()
 		}
-		if idleMemory > mostMemory {
-			mostMemoryNode.Node = node
-			mostMemoryNode.IdleResource.Memory = idleMemory
-			mostMemoryNode.IdleResource.MilliCpu = idleCpu
-			mostMemory = idleMemory
+	}
+}
+
+// Reconcile reports the pods run here for other clusters and our pods
+// awaiting a result to the coordinator, and acts on the results it sends
+// again.
+func (m *Member) Reconcile() {
+	state := types.MemberState{ClusterId: m.clusterId, Running: m.kube.imports()}
+	m.outsourcedToLock.Lock()
+	for _, awaited := range m.awaiting {
+		if m.clk.Now().Sub(awaited.since) >= awaitGrace {
+			state.Awaiting = append(state.Awaiting, awaited.pod)
 		}
 	}
+	m.outsourcedToLock.Unlock()
+	reconciliation, err := m.coordinator.Reconcile(state)
+	if err != nil {
+		rpcErrorCounter.WithLabelValues("Coordinator.Reconcile").Inc()
+		glog.Info(err)
+		return
+	}
+	if len(reconciliation.Results)+reconciliation.Requeued+reconciliation.Released+reconciliation.Charged > 0 {
+		glog.Infof("Reconciled: %d results, %d requeued, %d released, %d charged", len(reconciliation.Results),
+			reconciliation.Requeued, reconciliation.Released, reconciliation.Charged)
+	}
+	for _, result := range reconciliation.Results {
+		m.receiveResult(result)
+	}
+}
+
+// DeregisterCluster tells the coordinator that this cluster is leaving.
+func (m *Member) DeregisterCluster() {
+	err := m.coordinator.DeregisterCluster(m.clusterId)
+	if err != nil {
+		rpcErrorCounter.WithLabelValues("Coordinator.DeregisterCluster").Inc()
+		glog.Info(err)
+	}
+}
+
+// Heartbeat reports the cluster's capacity and idle nodes to the
+// coordinator, which also keeps the cluster from expiring.
+func (m *Member) Heartbeat() {
+	nodes := m.kube.getNodes()
+	total := m.getTotalResource()
+	cluster := types.Cluster{Id: m.clusterId, TotalResource: total, ReportsCapacity: true, IdleNodes: m.idleNodes(nodes, total), Nodes: nodes}
+	err := m.coordinator.Heartbeat(cluster)
+	// heartbeats are sent on every change, losing the coordinator is
+	// logged once when it happens.
+	if err != nil && err != errDisconnected {
+		rpcErrorCounter.WithLabelValues("Coordinator.Heartbeat").Inc()
+		glog.Info(err)
+	}
+}
+
+// idleNodes returns, for every dimension of total, the node with the most
+// of it idle. A node that leads several dimensions is reported once.
+func (m *Member) idleNodes(nodes []types.Node, total types.Resource) []types.InterNode {
+	dims := make([]string, 0)
+	for name := range total.Dims() {
+		dims = append(dims, name)
+	}
+	sort.Strings(dims)
+	allocated := m.kube.allocation()
 	idleNodes := make([]types.InterNode, 0)
-	mostCpuNode.ClusterId = clusterId
-	idleNodes = append(idleNodes, mostCpuNode)
-	if mostCpuNode.Node.Name != mostMemoryNode.Node.Name {
-		mostMemoryNode.ClusterId = clusterId
-		idleNodes = append(idleNodes, mostMemoryNode)
+	reported := make(map[string]bool)
+	for _, dim := range dims {
+		var most types.InterNode
+		var mostIdle int64
+		for _, node := range nodes {
+			idleRes := node.Resource.Sub(allocated[node.Name])
+			if idle := idleRes.Get(dim); idle > mostIdle {
+				most = types.InterNode{Node: node, IdleResource: idleRes, ClusterId: m.clusterId}
+				mostIdle = idle
+			}
+		}
+		if most.Node.Name != "" && !reported[most.Node.Name] {
+			reported[most.Node.Name] = true
+			idleNodes = append(idleNodes, most)
+		}
+	}
+	return idleNodes
+}
+
+// UploadPod hands pod to the coordinator. It fails with errDisconnected
+// only if the coordinator was not called. On any error the pod stays here
+// and is uploaded again, the coordinator drops pods it has already.
+func (m *Member) UploadPod(pod types.Pod) (float64, error) {
+	interPod := types.InterPod{Pod: pod, ClusterId: m.clusterId}
+	// a failed upload may still have reached the coordinator, Reconcile
+	// has it queued again otherwise.
+	m.outsourcedToLock.Lock()
+	m.awaiting[pod.Name] = awaitingPod{pod: pod, since: m.clk.Now()}
+	m.outsourcedToLock.Unlock()
+	weight, err := m.coordinator.UploadPod(interPod)
+	if err == errDisconnected || federationpb.Unreachable(err) {
+		m.outsourcedToLock.Lock()
+		delete(m.awaiting, pod.Name)
+		m.outsourcedToLock.Unlock()
+		return 0, err
 	}
-	cluster := types.Cluster{Id: clusterId, IdleNodes: idleNodes}
-	var reply int
-	err := client.Call("Server.Heartbeat", cluster, &reply)
 	if err != nil {
+		rpcErrorCounter.WithLabelValues("Coordinator.UploadPod").Inc()
 		glog.Info(err)
+	} else {
+		outsourcedCounter.Inc()
 	}
+	return weight, err
 }
 
-func UploadPod(pod types.Pod) float64 {
-	interPod := &types.InterPod{Pod: pod, ClusterId: clusterId}
-	var reply float64
-	err := client.Call("Server.UploadPod", interPod, &reply)
+// UploadPodGroup asks the coordinator to reserve room for a whole group.
+func (m *Member) UploadPodGroup(group types.PodGroup) (types.GroupReservation, error) {
+	interGroup := types.InterPodGroup{PodGroup: group, ClusterId: m.clusterId}
+	reservation, err := m.coordinator.UploadPodGroup(interGroup)
+	if err != nil {
+		rpcErrorCounter.WithLabelValues("Coordinator.UploadPodGroup").Inc()
+	} else {
+		outsourcedCounter.Add(float64(len(group.Pods)))
+	}
+	return reservation, err
+}
+
+func (m *Member) ReleasePod(pod types.InterPod) {
+	err := m.coordinator.ReleasePod(pod)
 	if err != nil {
+		rpcErrorCounter.WithLabelValues("Coordinator.ReleasePod").Inc()
 		glog.Info(err)
 	}
-	return reply
 }
 
-func ReturnScheduleData(result types.ScheduleData) {
-	// connect to otherCluster
-	var err error
-	clusterIp := otherClustersPod[result.Pod.Name]
-	cli, err := rpc.DialHTTP("tcp", clusterIp+":"+clientPort)
+// ReleaseSourcePod tells the source cluster of an outsourced pod that the pod
+// has finished, so the owning tenant's share can shrink.
+func (m *Member) ReleaseSourcePod(pod types.Pod, source peer) {
+	conn, err := m.dialMember(source)
 	if err != nil {
+		rpcErrorCounter.WithLabelValues("Member.ReleasePod").Inc()
 		glog.Info(err)
+		return
 	}
+	defer conn.Close()
+	err = conn.ReleasePod(pod)
+	if err != nil {
+		rpcErrorCounter.WithLabelValues("Member.ReleasePod").Inc()
+		glog.Info(err)
+	}
+}
 
-	var reply int
-	err = cli.Call("Server.ReturnScheduleData", &result, &reply)
+func (m *Member) ReturnScheduleData(result types.ScheduleData) {
+	// connect to otherCluster
+	source, _ := m.kube.sourceOf(result.Pod.Name)
+	conn, err := m.dialMember(source)
 	if err != nil {
+		rpcErrorCounter.WithLabelValues("Member.ReturnScheduleData").Inc()
 		glog.Info(err)
+		return
 	}
+	defer conn.Close()
+	err = conn.ReturnScheduleData(result)
+	if err != nil {
+		rpcErrorCounter.WithLabelValues("Member.ReturnScheduleData").Inc()
+		glog.Info(err)
+	}
+}
+
+// rpcCoordinator calls a coordinator that predates the gRPC protocol.
+type rpcCoordinator struct {
+	client *rpc.Client
+}
+
+func dialCoordinatorRpc(addr string, config *tls.Config) (CoordinatorConn, error) {
+	client, err := federationpb.DialRPC(addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return rpcCoordinator{client}, nil
+}
+
+func (c rpcCoordinator) RegisterCluster(cluster types.Cluster) (uint32, error) {
+	var reply int
+	return 0, c.client.Call("Server.RegisterCluster", cluster, &reply)
+}
+
+func (c rpcCoordinator) Heartbeat(cluster types.Cluster) error {
+	var reply int
+	return c.client.Call("Server.Heartbeat", cluster, &reply)
+}
+
+func (c rpcCoordinator) DeregisterCluster(id string) error {
+	var reply int
+	return c.client.Call("Server.DeregisterCluster", &id, &reply)
+}
+
+func (c rpcCoordinator) UploadPod(pod types.InterPod) (float64, error) {
+	var reply float64
+	err := c.client.Call("Server.UploadPod", &pod, &reply)
+	return reply, err
+}
+
+func (c rpcCoordinator) UploadPodGroup(group types.InterPodGroup) (types.GroupReservation, error) {
+	var reply types.GroupReservation
+	err := c.client.Call("Server.UploadPodGroup", &group, &reply)
+	return reply, err
+}
+
+func (c rpcCoordinator) ReleasePod(pod types.InterPod) error {
+	var reply int
+	return c.client.Call("Server.ReleasePod", &pod, &reply)
+}
+
+func (c rpcCoordinator) ConfirmPlacement(placement types.Placement) error {
+	var reply int
+	return c.client.Call("Server.ConfirmPlacement", &placement, &reply)
+}
+
+func (c rpcCoordinator) Reconcile(state types.MemberState) (types.Reconciliation, error) {
+	var reply types.Reconciliation
+	err := c.client.Call("Server.Reconcile", &state, &reply)
+	return reply, err
+}
+
+func (c rpcCoordinator) Close() error {
+	return c.client.Close()
+}
+
+// rpcMember calls a member that predates the gRPC protocol.
+type rpcMember struct {
+	client *rpc.Client
+}
+
+func (m rpcMember) CreatePod(pod types.OutsourcePod) error {
+	var reply int
+	return m.client.Call("Server.CreatePod", &pod, &reply)
+}
+
+func (m rpcMember) ReturnScheduleData(data types.ScheduleData) error {
+	var reply int
+	return m.client.Call("Server.ReturnScheduleData", &data, &reply)
+}
+
+func (m rpcMember) ReleasePod(pod types.Pod) error {
+	var reply int
+	return m.client.Call("Server.ReleasePod", &pod, &reply)
+}
+
+func (m rpcMember) Close() error {
+	return m.client.Close()
 }
@@ -1,100 +1,154 @@
 package scheduler
 
 import (
-	"os"
-	"strconv"
+	"events"
 	"strings"
-	"time"
 	"types"
 
 	"github.com/golang/glog"
 	v1 "k8s.io/api/core/v1"
 )
 
-var (
-	podInfo             map[string]v1.Pod // local pod
-	scheduleDataQ       chan types.ScheduleData
-	executeDataQ        chan types.ExecuteData
-	userDataQ           chan types.UserData
-	startTime           int64
-	usedCpu, usedMemory int64
-)
-
-func init() {
-	podInfo = make(map[string]v1.Pod)
-	scheduleDataQ = make(chan types.ScheduleData, 10)
-	executeDataQ = make(chan types.ExecuteData, 10)
-	userDataQ = make(chan types.UserData, 10)
-	startTime = time.Now().Unix()
+func (m *Member) HandleData() {
+	m.openSinks()
+	go m.HandleScheduleData()
+	go m.HandleExecuteData()
+	go m.HandleUserData()
 }
 
-func HandleData() {
-	go HandleScheduleData()
-	go HandleExecuteData()
-	go HandleUserData()
+// openSinks opens the event files. Records are dropped if one cannot be
+// opened, the scheduler keeps running.
+func (m *Member) openSinks() {
+	m.scheduleSink = m.openSink("scheduleData")
+	m.clusterSink = m.openSink("clusterData")
+	m.userSink = m.openSink("userData")
 }
 
-func HandleScheduleData() {
-	filename := "../scheduleData.csv"
-	fd, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+func (m *Member) openSink(name string) *events.Sink {
+	sink, err := events.Open(name, m.eventsConfig)
 	if err != nil {
-		glog.Error()
+		glog.Errorf("%s records are dropped: %v", name, err)
 	}
-	var content string
-	var totalWaitTime int64
-	totalWaitTime = 0
-	for data := range scheduleDataQ {
-		podName := data.Name[strings.IndexAny(data.Name, "-")+1:]
-		stamp := podInfo[podName].CreationTimestamp
-		waitTime := data.StartTime - stamp.ProtoTime().Seconds
-		totalWaitTime += waitTime
-		content = strconv.FormatInt(time.Now().Unix()-startTime, 10) + "," + podInfo[podName].Namespace + "," + podName +
-			"," + strconv.FormatInt(stamp.ProtoTime().Seconds, 10) + "," + strconv.FormatInt(data.CreateTime, 10) +
-			"," + strconv.FormatInt(data.StartTime, 10) + "," + strconv.FormatInt(waitTime, 10) +
-			"," + strconv.FormatInt(totalWaitTime, 10) + "\n"
-		buf := []byte(content)
-		fd.Write(buf)
+	return sink
+}
+
+// Close closes the event files.
+func (m *Member) Close() {
+	for _, sink := range []*events.Sink{m.scheduleSink, m.clusterSink, m.userSink} {
+		if sink != nil {
+			sink.Close()
+		}
 	}
-	defer fd.Close()
 }
 
-func HandleExecuteData() {
-	filename := "../clusterData.csv"
-	fd, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
-	if err != nil {
-		glog.Error()
+func writeRecord(sink *events.Sink, r events.Record) {
+	if sink == nil {
+		return
 	}
-	var content string
-	for data := range executeDataQ {
-		if data.Status == "running" {
-			usedCpu += data.RequestMilliCpu
-			usedMemory += data.RequestMemory
-		} else if data.Status == "finish" {
-			usedCpu -= data.RequestMilliCpu
-			usedMemory -= data.RequestMemory
-		}
-		cpuUsedRate := float64(usedCpu) / float64(totalCpu)
-		memUsedRate := float64(usedMemory) / float64(totalMemory)
-		content = strconv.FormatInt(data.CurrentTime-startTime, 10) + "," + strconv.FormatFloat(cpuUsedRate, 'f', 4, 64) + "," +
-			strconv.FormatFloat(memUsedRate, 'f', 4, 64) + "\n"
-		buf := []byte(content)
-		fd.Write(buf)
+	if err := sink.Write(r); err != nil {
+		glog.Error(err)
 	}
-	defer fd.Close()
 }
 
-func HandleUserData() {
-	filename := "../userData.csv"
-	fd, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
-	if err != nil {
-		glog.Error()
+// queueScheduleData, queueExecuteData and queueUserData hand data to its
+// Handle loop, or write it right away when stepping.
+func (m *Member) queueScheduleData(data types.ScheduleData) {
+	if m.stepping {
+		m.writeScheduleData(data)
+		return
 	}
-	var content string
-	for data := range userDataQ {
-		content = data.Uid + "," + strconv.FormatInt(data.CurrentTime-startTime, 10) + "," + strconv.FormatFloat(data.Share, 'f', 4, 64) + "," +
-			strconv.FormatInt(data.MilliCpu, 10) + "," + strconv.FormatInt(data.Memory, 10) + "," + "\n"
-		buf := []byte(content)
-		fd.Write(buf)
+	m.scheduleDataQ <- data
+}
+
+func (m *Member) queueExecuteData(data types.ExecuteData) {
+	if m.stepping {
+		m.writeExecuteData(data)
+		return
+	}
+	m.executeDataQ <- data
+}
+
+func (m *Member) queueUserData(data types.UserData) {
+	if m.stepping {
+		m.writeUserData(data)
+		return
 	}
-	defer fd.Close()
+	m.userDataQ <- data
+}
+
+// setPodInfo keeps a local pod for the records and for outsourcing it.
+func (m *Member) setPodInfo(pod v1.Pod) {
+	m.podInfoLock.Lock()
+	m.podInfo[pod.Name] = pod
+	m.podInfoLock.Unlock()
+}
+
+func (m *Member) getPodInfo(name string) (v1.Pod, bool) {
+	m.podInfoLock.RLock()
+	defer m.podInfoLock.RUnlock()
+	pod, ok := m.podInfo[name]
+	return pod, ok
+}
+
+func (m *Member) HandleScheduleData() {
+	for data := range m.scheduleDataQ {
+		m.writeScheduleData(data)
+	}
+}
+
+func (m *Member) writeScheduleData(data types.ScheduleData) {
+	// pods that ran on another cluster are named after us there.
+	podName := strings.TrimPrefix(data.Name, m.clusterId+"-")
+	info, _ := m.getPodInfo(podName)
+	stamp := info.CreationTimestamp
+	waitTime := data.StartTime - stamp.ProtoTime().Seconds
+	m.totalWaitTime += waitTime
+	writeRecord(m.scheduleSink, events.ScheduleRecord{
+		Time:      m.clk.Now().Unix() - m.startTime,
+		Tenant:    info.Namespace,
+		Pod:       podName,
+		Submitted: stamp.ProtoTime().Seconds,
+		Created:   data.CreateTime,
+		Started:   data.StartTime,
+		Wait:      waitTime,
+		TotalWait: m.totalWaitTime,
+	})
+}
+
+func (m *Member) HandleExecuteData() {
+	for data := range m.executeDataQ {
+		m.writeExecuteData(data)
+	}
+}
+
+func (m *Member) writeExecuteData(data types.ExecuteData) {
+	if data.Status == "running" {
+		m.usedCpu += data.RequestMilliCpu
+		m.usedMemory += data.RequestMemory
+	} else if data.Status == "finish" {
+		m.usedCpu -= data.RequestMilliCpu
+		m.usedMemory -= data.RequestMemory
+	}
+	total := m.getTotalResource()
+	writeRecord(m.clusterSink, events.UsageRecord{
+		Time:   data.CurrentTime - m.startTime,
+		Cpu:    float64(m.usedCpu) / float64(total.MilliCpu),
+		Memory: float64(m.usedMemory) / float64(total.Memory),
+	})
+}
+
+func (m *Member) HandleUserData() {
+	for data := range m.userDataQ {
+		m.writeUserData(data)
+	}
+}
+
+func (m *Member) writeUserData(data types.UserData) {
+	writeRecord(m.userSink, events.TenantShareRecord{
+		Tenant:   data.Uid,
+		Time:     data.CurrentTime - m.startTime,
+		Share:    data.Share,
+		MilliCpu: data.MilliCpu,
+		Memory:   data.Memory,
+	})
 }
@@ -8,108 +8,324 @@ import (
 	"github.com/golang/glog"
 )
 
-var (
-	usersPriorityQ types.PriorityQueue
-	usersPresent   map[string]bool //userPresent[Uid] == true means that the Uid has been in usersPriorityQ.
-	usersActiveQ   chan string
-	usersPodsQ     map[string]chan types.Pod
-	highPriorityCh chan types.Pod
+func (m *Member) DispatchPods() {
+	for pod := range m.kube.pendingPodCh {
+		if pod.Group != "" {
+			var ok bool
+			if pod, ok = m.collectGroupPod(pod); !ok {
+				continue
+			}
+		}
+		m.dispatch(pod)
+	}
+}
+
+// dispatch adds pod to the queue of its tenant.
+func (m *Member) dispatch(pod types.Pod) {
+	value, ok := m.usersPodsQ[pod.Uid]
+	if !ok {
+		m.usersPodsQ[pod.Uid] = make(chan types.Pod, 20)
+		value = m.usersPodsQ[pod.Uid]
+	}
+	if len(value) == 0 {
+		m.usersActiveQ <- pod.Uid
+	}
+	value <- pod
+	m.recordQueue(pod.Uid, value)
+}
+
+// The backoff of a parked pod doubles from parkMin up to parkMax.
+const (
+	parkMin = time.Second
+	parkMax = 30 * time.Second
 )
 
-func init() {
-	usersPresent = make(map[string]bool)
-	usersActiveQ = make(chan string, 10)
-	usersPodsQ = make(map[string]chan types.Pod)
-	highPriorityCh = make(chan types.Pod, 10)
+// waitingPod is a pod, or the group a placeholder stands for, being
+// scheduled. If it can neither run here nor be handed to the coordinator
+// yet it is parked: its tenant is left out of usersPriorityQ until the
+// backoff has passed, and the pod is tried first once it is back.
+type waitingPod struct {
+	user  *types.User // the tenant whose turn it is, nil for pods of other clusters
+	pod   types.Pod
+	group *types.PodGroup
+	held  bool // logged as waiting for the coordinator
+	// uploaded is set once an upload failed after calling the coordinator,
+	// which may have queued the pod all the same.
+	uploaded bool
+	backoff  time.Duration
+	retry    time.Time // when a parked pod is tried again
 }
 
-func DispatchPods() {
-	for pod := range pendingPodCh {
-		value, ok := usersPodsQ[pod.Uid]
-		if !ok {
-			usersPodsQ[pod.Uid] = make(chan types.Pod, 20)
-			value = usersPodsQ[pod.Uid]
-		}
-		if len(value) == 0 {
-			usersActiveQ <- pod.Uid
+func (m *Member) Schedule() {
+	for {
+		if !m.ScheduleOnce() {
+			m.clk.Sleep(time.Second)
 		}
-		value <- pod
 	}
 }
 
-func Schedule() {
-	for {
-		// schedule pod in highPriorityCh at first
-		select {
-		case pod := <-highPriorityCh:
-			schedulePod(pod)
+// ScheduleOnce is one round of Schedule. The pods other clusters sent are
+// tried first, and otherwise the tenant with the lowest share schedules its
+// next pod. It reports whether the next round follows right away, which it
+// does after a pod of another cluster.
+func (m *Member) ScheduleOnce() bool {
+	// schedule pods of other clusters at first
+	if w := m.dueImport(); w != nil {
+		return m.place(w)
+	}
+	select {
+	case pod := <-m.highPriorityCh:
+		return m.place(&waitingPod{pod: pod})
+	default:
+	}
+
+	// release finished pods, apply tenant changes and reorder usersPriorityQ
+	changed := false
+	finishedPodChLen := len(m.finishedPodCh)
+	for i := 0; i < finishedPodChLen; i++ {
+		if m.releaseUserPod(<-m.finishedPodCh) {
+			changed = true
+		}
+	}
+	if finishedPodChLen > 0 {
+		// parked pods may fit into what the finished pods left.
+		m.retryParked()
+	}
+	tenantChLen := len(m.tenantCh)
+	for i := 0; i < tenantChLen; i++ {
+		tenant := <-m.tenantCh
+		if tenant.deleted {
+			m.removeUser(tenant.uid)
+			changed = true
 			continue
-		default:
 		}
+		m.setUserLocal(tenant.uid, tenant.local)
+		if m.setUserWeight(tenant.uid, tenant.weight) {
+			changed = true
+		}
+	}
+	if changed {
+		// users without a weight of their own, such as those whose
+		// namespace is not known yet, stay queued at the default weight.
+		for _, user := range m.usersPriorityQ {
+			user.Priority = m.getUserShare(user.Uid)
+		}
+		heap.Init(&m.usersPriorityQ)
+	}
 
-		// fix usersPriorityQ
-		usersActiveQLen := len(usersActiveQ)
-		for i := 0; i < usersActiveQLen; i++ {
-			uid := <-usersActiveQ
-			present, ok := usersPresent[uid]
-			if ok && present {
-				continue
-			} else {
-				usersPresent[uid] = true
-				user := &types.User{
-					Uid:      uid,
-					Priority: getUserShare(uid),
-				}
-				heap.Push(&usersPriorityQ, user)
-			}
+	// fix usersPriorityQ, tenants with a parked pod come back once it is due
+	now := m.clk.Now()
+	for uid, w := range m.parked {
+		if !w.retry.After(now) && !m.usersPresent[uid] {
+			m.activate(uid)
 		}
+	}
+	usersActiveQLen := len(m.usersActiveQ)
+	for i := 0; i < usersActiveQLen; i++ {
+		uid := <-m.usersActiveQ
+		if _, parked := m.parked[uid]; parked || m.usersPresent[uid] {
+			continue
+		}
+		m.activate(uid)
+	}
 
-		// schedule local pod
-		if len(usersPriorityQ) > 0 {
-			topUser := heap.Pop(&usersPriorityQ).(*types.User)
-			select {
-			case firstPod := <-usersPodsQ[topUser.Uid]:
-				glog.Info("=============================")
-				glog.Info("Before Schedule()")
-				printShare()
-				weight := schedulePod(firstPod)
-				topUser.Priority = fixUserShare(firstPod, weight)
-				heap.Push(&usersPriorityQ, topUser)
-				glog.Info("After Schedule()")
-				printShare()
-				glog.Info("=============================")
-				userData := types.UserData{
-					Uid:         firstPod.Uid,
-					CurrentTime: time.Now().Unix(),
-					Share:       topUser.Priority,
-					Resource:    usersAllocatedRes[firstPod.Uid],
+	// schedule local pod
+	if len(m.usersPriorityQ) > 0 {
+		topUser := heap.Pop(&m.usersPriorityQ).(*types.User)
+		if w, ok := m.parked[topUser.Uid]; ok {
+			delete(m.parked, topUser.Uid)
+			w.user = topUser
+			m.place(w)
+			return false
+		}
+		select {
+		case firstPod := <-m.usersPodsQ[topUser.Uid]:
+			m.recordQueue(topUser.Uid, m.usersPodsQ[topUser.Uid])
+			w := &waitingPod{user: topUser, pod: firstPod}
+			if firstPod.Group != "" && firstPod.Name == "" {
+				group, ok := m.takeGroup(firstPod)
+				if !ok {
+					topUser.Priority = m.getUserShare(topUser.Uid)
+					heap.Push(&m.usersPriorityQ, topUser)
+					return false
 				}
-				userDataQ <- userData
-			default:
-				usersPresent[topUser.Uid] = false
+				w.group = &group
 			}
+			m.place(w)
+		default:
+			m.usersPresent[topUser.Uid] = false
 		}
-		time.Sleep(time.Second)
 	}
+	return false
 }
 
-func schedulePod(pod types.Pod) float64 {
-	for {
-		nodes := getNodes()
-		for _, node := range nodes {
-			res := allocatedResource[node.Name]
-			if res.MilliCpu+pod.RequestMilliCpu <= node.MilliCpu && res.Memory+pod.RequestMemory <= node.Memory {
-				schedulePodToNode(pod, node)
-				Heartbeat()
-				return 0
-			}
+// activate queues tenant uid in usersPriorityQ.
+func (m *Member) activate(uid string) {
+	m.usersPresent[uid] = true
+	user := &types.User{
+		Uid:      uid,
+		Priority: m.getUserShare(uid),
+	}
+	heap.Push(&m.usersPriorityQ, user)
+}
+
+// place schedules the pod or group of w, which is parked if it cannot be
+// placed yet. It reports whether a pod of another cluster was bound.
+func (m *Member) place(w *waitingPod) bool {
+	if w.user == nil {
+		if !m.scheduleImported(w.pod) {
+			m.park(w)
+			return false
+		}
+		return true
+	}
+	glog.Info("=============================")
+	glog.Info("Before Schedule()")
+	m.printShare()
+	var weight float64
+	var ok bool
+	if w.group != nil {
+		weight, ok = m.scheduleGroup(w)
+	} else {
+		weight, ok = m.schedulePod(w)
+	}
+	if !ok {
+		m.park(w)
+		return false
+	}
+	if w.group != nil {
+		w.user.Priority = m.getUserShare(w.group.Uid)
+		for _, pod := range w.group.Pods {
+			w.user.Priority = m.fixUserShare(pod, weight)
+		}
+	} else {
+		w.user.Priority = m.fixUserShare(w.pod, weight)
+	}
+	heap.Push(&m.usersPriorityQ, w.user)
+	glog.Info("After Schedule()")
+	m.printShare()
+	glog.Info("=============================")
+	userData := types.UserData{
+		Uid:         w.user.Uid,
+		CurrentTime: m.clk.Now().Unix(),
+		Share:       w.user.Priority,
+		Resource:    m.usersAllocatedRes[w.user.Uid],
+	}
+	m.queueUserData(userData)
+	return false
+}
+
+// park sets w aside until its backoff has passed, doubling the backoff
+// every time. Its tenant leaves usersPriorityQ meanwhile, so that other
+// tenants are scheduled.
+func (m *Member) park(w *waitingPod) {
+	w.backoff *= 2
+	if w.backoff < parkMin {
+		w.backoff = parkMin
+	} else if w.backoff > parkMax {
+		w.backoff = parkMax
+	}
+	w.retry = m.clk.Now().Add(w.backoff)
+	if w.user == nil {
+		m.parkedImports = append(m.parkedImports, w)
+		return
+	}
+	m.usersPresent[w.user.Uid] = false
+	m.parked[w.user.Uid] = w
+}
+
+// dueImport takes the first parked pod of another cluster whose backoff
+// has passed, if any.
+func (m *Member) dueImport() *waitingPod {
+	now := m.clk.Now()
+	for i, w := range m.parkedImports {
+		if !w.retry.After(now) {
+			m.parkedImports = append(m.parkedImports[:i], m.parkedImports[i+1:]...)
+			return w
+		}
+	}
+	return nil
+}
+
+// retryParked makes every parked pod due.
+func (m *Member) retryParked() {
+	now := m.clk.Now()
+	for _, w := range m.parked {
+		w.retry = now
+	}
+	for _, w := range m.parkedImports {
+		w.retry = now
+	}
+}
+
+// tenantLocal tells whether pods of tenant uid that do not fit here are kept
+// here, by the tenant's override or else the cluster's local.
+func (m *Member) tenantLocal(uid string) bool {
+	if override, ok := m.usersLocal[uid]; ok {
+		return override
+	}
+	return m.local
+}
+
+// outsourcing tells whether pods of tenant uid that do not fit here go to
+// the coordinator, which they do only while it is reached.
+func (m *Member) outsourcing(uid string) bool {
+	return !m.tenantLocal(uid) && m.coordinator.Connected()
+}
+
+// schedulePod binds the pod of w here or uploads it to the coordinator,
+// and reports false if it has to wait.
+func (m *Member) schedulePod(w *waitingPod) (float64, bool) {
+	pod := w.pod
+	if w.uploaded && m.resultReceived(pod.Name) {
+		// a failed upload reached the coordinator, whose result came in
+		// while the pod waited.
+		m.recordScheduled(pod, "outsourced")
+		m.kube.deletePodByName(pod.Name, pod.Uid)
+		return 0, true
+	}
+	if node, ok := m.selectNode(pod, m.kube.getNodes()); ok {
+		if w.uploaded {
+			// the coordinator's placement, if any, is released by
+			// Reconcile once the destination does not run the pod.
+			m.outsourcedToLock.Lock()
+			delete(m.awaiting, pod.Name)
+			m.outsourcedToLock.Unlock()
 		}
-		if local == false {
-			// if cluster doesn't have enough resourse, outsource the pod.
-			weight := UploadPod(pod)
-			deletePodByName(pod.Name, pod.Uid)
-			return weight
+		m.schedulePodToNode(pod, node)
+		m.recordScheduled(pod, "local")
+		m.Heartbeat()
+		return 0, true
+	}
+	if m.outsourcing(pod.Uid) {
+		// if cluster doesn't have enough resourse, outsource the pod.
+		weight, err := m.UploadPod(pod)
+		if err == nil {
+			m.recordScheduled(pod, "outsourced")
+			m.kube.deletePodByName(pod.Name, pod.Uid)
+			return weight, true
 		}
-		time.Sleep(time.Second)
+		if err != errDisconnected {
+			// the pod is only deleted here once the coordinator has it.
+			glog.Infof("%s waits here until it is uploaded.", pod.Name)
+			w.uploaded = true
+		}
+	} else if !w.held && !m.tenantLocal(pod.Uid) {
+		glog.Infof("%s waits here until the coordinator is back.", pod.Name)
+		w.held = true
+	}
+	return 0, false
+}
+
+// scheduleImported binds a pod the coordinator placed here. It waits for a
+// node with room rather than being outsourced again.
+func (m *Member) scheduleImported(pod types.Pod) bool {
+	node, ok := m.selectNode(pod, m.kube.getNodes())
+	if !ok {
+		return false
 	}
+	m.schedulePodToNode(pod, node)
+	m.recordScheduled(pod, "local")
+	m.Heartbeat()
+	return true
 }
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"types"
+)
+
+// NodeScorer ranks the nodes a pod fits on, the node with the highest score
+// is chosen. allocated is what the node has given out before the pod.
+type NodeScorer func(pod types.Pod, node types.Node, allocated types.Resource) float64
+
+var scorers = map[string]NodeScorer{
+	"first-fit":       firstFit,
+	"least-allocated": leastAllocated,
+	"most-allocated":  mostAllocated,
+	"balanced":        balancedResource,
+	"random":          randomFeasible,
+}
+
+// ScoringStrategies lists the names of the node scoring strategies.
+func ScoringStrategies() []string {
+	names := make([]string, 0, len(scorers))
+	for name := range scorers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkScoring checks that the default strategy name and those of
+// perScheduler are known.
+func checkScoring(name string, perScheduler map[string]string) error {
+	if _, ok := scorers[name]; !ok {
+		return fmt.Errorf("unknown scoring strategy %q, want one of %v", name, ScoringStrategies())
+	}
+	for schedulerName, s := range perScheduler {
+		if _, ok := scorers[s]; !ok {
+			return fmt.Errorf("unknown scoring strategy %q for %s, want one of %v", s, schedulerName, ScoringStrategies())
+		}
+	}
+	return nil
+}
+
+func (m *Member) scorerFor(pod types.Pod) NodeScorer {
+	if name, ok := m.schedulerScorer[pod.SchedulerName]; ok {
+		return scorers[name]
+	}
+	return scorers[m.defaultScorer]
+}
+
+// selectNode returns the best node pod fits on, or false if it fits nowhere.
+func (m *Member) selectNode(pod types.Pod, nodes []types.Node) (types.Node, bool) {
+	return m.selectNodeWith(pod, nodes, m.kube.allocation())
+}
+
+// selectNodeWith is selectNode against a given allocation of the nodes.
+func (m *Member) selectNodeWith(pod types.Pod, nodes []types.Node, allocated map[string]types.Resource) (types.Node, bool) {
+	score := m.scorerFor(pod)
+	var best types.Node
+	bestScore := math.Inf(-1)
+	found := false
+	for _, node := range nodes {
+		res := allocated[node.Name]
+		if !res.Add(pod.Request()).Fits(node.Resource) {
+			continue
+		}
+		if !pod.Constraints.Fits(node) {
+			continue
+		}
+		if s := score(pod, node, res); s > bestScore {
+			best, bestScore, found = node, s, true
+		}
+	}
+	return best, found
+}
+
+// usedFraction returns the cpu and memory fractions of node in use once pod
+// is placed on it. Like the Kubernetes scorers, only cpu and memory count.
+func usedFraction(pod types.Pod, node types.Node, allocated types.Resource) (float64, float64) {
+	used := allocated.Add(pod.Request())
+	cpu, ok := used.Fraction(types.ResourceCpu, node.Resource)
+	if !ok {
+		cpu = 1
+	}
+	memory, ok := used.Fraction(types.ResourceMemory, node.Resource)
+	if !ok {
+		memory = 1
+	}
+	return cpu, memory
+}
+
+// firstFit keeps the order of getNodes().
+func firstFit(pod types.Pod, node types.Node, allocated types.Resource) float64 {
+	return 0
+}
+
+// leastAllocated spreads pods over the emptiest nodes.
+func leastAllocated(pod types.Pod, node types.Node, allocated types.Resource) float64 {
+	cpu, memory := usedFraction(pod, node, allocated)
+	return 1 - (cpu+memory)/2
+}
+
+// mostAllocated packs pods onto the fullest nodes to keep others free.
+func mostAllocated(pod types.Pod, node types.Node, allocated types.Resource) float64 {
+	cpu, memory := usedFraction(pod, node, allocated)
+	return (cpu + memory) / 2
+}
+
+// balancedResource prefers nodes whose cpu and memory usage stay close, so
+// neither is stranded.
+func balancedResource(pod types.Pod, node types.Node, allocated types.Resource) float64 {
+	cpu, memory := usedFraction(pod, node, allocated)
+	return 1 - math.Abs(cpu-memory)
+}
+
+func randomFeasible(pod types.Pod, node types.Node, allocated types.Resource) float64 {
+	return rand.Float64()
+}
@@ -1,73 +1,208 @@
 package scheduler
 
 import (
+	"strconv"
 	"types"
 
 	"github.com/golang/glog"
 )
 
-var (
-	usersShare            map[string]float64
-	usersAllocatedRes     map[string]types.Resource
-	usersWeight           map[string]float64
-	totalCpu, totalMemory int64
+const (
+	// weightKey is the namespace annotation, or label, holding a tenant's
+	// weight.
+	weightKey = "federation-scheduler/weight"
+	// localKey is the namespace annotation, or label, that overrides local
+	// for a tenant: "true" keeps its pods here, "false" outsources them
+	// even if the cluster does not.
+	localKey = "federation-scheduler/local"
 )
 
-func init() {
-	usersShare = make(map[string]float64)
-	usersAllocatedRes = make(map[string]types.Resource)
-	usersWeight = make(map[string]float64)
+// tenantEvent registers, reweights or, if deleted, removes a tenant. local
+// is the tenant's override of local, nil if it has none.
+type tenantEvent struct {
+	uid     string
+	weight  float64
+	local   *bool
+	deleted bool
 }
 
-func initShare() {
-	namespaces := getNamespaces()
+func (m *Member) initShare() {
+	namespaces := m.getNamespaces()
 	for _, ns := range namespaces {
 		var res types.Resource
-		usersAllocatedRes[ns] = res
-		usersShare[ns] = 0
-		usersWeight[ns] = 1
+		m.usersAllocatedRes[ns.Name] = res
+		m.usersShare[ns.Name] = 0
+		m.usersWeight[ns.Name] = namespaceWeight(ns.Annotations, ns.Labels)
+		m.setUserLocal(ns.Name, namespaceLocal(ns.Annotations, ns.Labels))
 	}
-	nodes := getNodes()
-	for _, node := range nodes {
-		totalCpu += node.MilliCpu
-		totalMemory += node.Memory
-	}
-	pods := getRunningPods()
+	m.fixTotalResource()
+	pods := m.kube.getRunningPods()
 	for _, pod := range pods {
-		res := usersAllocatedRes[pod.Uid]
-		res.MilliCpu += pod.RequestMilliCpu
-		res.Memory += pod.RequestMemory
-		usersAllocatedRes[pod.Uid] = res
-		usersShare[pod.Uid] = max(float64(res.MilliCpu)/float64(totalCpu), float64(res.Memory)/float64(totalMemory))
+		m.usersAllocatedRes[pod.Uid] = m.usersAllocatedRes[pod.Uid].Add(pod.Request())
+		m.usersPods[podKey(pod)] = pod
+		m.computeUserShare(pod.Uid, 0)
 	}
 	glog.Info("share is completed.")
 }
 
-func printShare() {
-	for k, v := range usersShare {
-		glog.Infof("%s's dominant share:%.2f", k, v)
+// fixTotalResource sums the capacity of the available nodes.
+func (m *Member) fixTotalResource() {
+	var total types.Resource
+	for _, node := range m.kube.getNodes() {
+		total = total.Add(node.Resource)
+	}
+	m.totalLock.Lock()
+	m.totalResource = total
+	m.totalLock.Unlock()
+}
+
+func (m *Member) getTotalResource() types.Resource {
+	m.totalLock.RLock()
+	defer m.totalLock.RUnlock()
+	return m.totalResource
+}
+
+func (m *Member) printShare() {
+	for k, v := range m.usersShare {
+		glog.Infof("%s's dominant share:%.2f, weight:%.2f", k, v, m.getUserWeight(k))
+	}
+}
+
+// namespaceWeight reads a tenant's weight from the namespace annotations,
+// then labels, defaulting to 1.
+func namespaceWeight(annotations, labels map[string]string) float64 {
+	value, ok := annotations[weightKey]
+	if !ok {
+		value, ok = labels[weightKey]
+	}
+	if !ok {
+		return 1
+	}
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil || weight <= 0 {
+		glog.Warningf("invalid %s %q, use 1", weightKey, value)
+		return 1
+	}
+	return weight
+}
+
+// namespaceLocal reads a tenant's override of local from the namespace
+// annotations, then labels, nil if it has none.
+func namespaceLocal(annotations, labels map[string]string) *bool {
+	value, ok := annotations[localKey]
+	if !ok {
+		value, ok = labels[localKey]
+	}
+	if !ok {
+		return nil
+	}
+	local, err := strconv.ParseBool(value)
+	if err != nil {
+		glog.Warningf("invalid %s %q, ignore it", localKey, value)
+		return nil
+	}
+	return &local
+}
+
+// setUserLocal sets or, if override is nil, clears a tenant's override of
+// local.
+func (m *Member) setUserLocal(uid string, override *bool) {
+	old, had := m.usersLocal[uid]
+	if override == nil {
+		if had {
+			delete(m.usersLocal, uid)
+			glog.Infof("%s's local:%t, as the cluster's", uid, m.local)
+		}
+		return
+	}
+	if had && old == *override {
+		return
+	}
+	m.usersLocal[uid] = *override
+	glog.Infof("%s's local:%t", uid, *override)
+}
+
+// setUserWeight changes a tenant's base weight and recomputes its share. It
+// returns false if the weight did not change.
+func (m *Member) setUserWeight(uid string, weight float64) bool {
+	if old, ok := m.usersWeight[uid]; ok && old == weight {
+		return false
+	}
+	m.usersWeight[uid] = weight
+	m.computeUserShare(uid, 0)
+	glog.Infof("%s's weight:%.2f", uid, weight)
+	return true
+}
+
+// removeUser forgets a deleted tenant and drops its queued pods, which were
+// deleted together with the namespace.
+func (m *Member) removeUser(uid string) {
+	delete(m.usersWeight, uid)
+	delete(m.usersLocal, uid)
+	delete(m.usersShare, uid)
+	delete(m.usersAllocatedRes, uid)
+	for key, pod := range m.usersPods {
+		if pod.Uid == uid {
+			delete(m.usersPods, key)
+		}
+	}
+	m.forgetGroups(uid)
+	delete(m.parked, uid)
+	if podsQ, ok := m.usersPodsQ[uid]; ok {
+		for len(podsQ) > 0 {
+			pod := <-podsQ
+			glog.Infof("Drop %s of deleted tenant %s.", pod.Name, uid)
+		}
+	}
+	m.forgetTenant(uid)
+	glog.Infof("Remove tenant %s.", uid)
+}
+
+func (m *Member) getUserWeight(uid string) float64 {
+	if w, ok := m.usersWeight[uid]; ok && w > 0 {
+		return w
 	}
+	return 1
+}
+
+func (m *Member) fixUserShare(pod types.Pod, weight float64) float64 {
+	m.usersAllocatedRes[pod.Uid] = m.usersAllocatedRes[pod.Uid].Add(pod.Request())
+	m.usersPods[podKey(pod)] = pod
+	return m.computeUserShare(pod.Uid, weight)
 }
 
-func fixUserShare(pod types.Pod, weight float64) float64 {
-	res := usersAllocatedRes[pod.Uid]
-	res.MilliCpu += pod.RequestMilliCpu
-	res.Memory += pod.RequestMemory
-	usersAllocatedRes[pod.Uid] = res
-	w := usersWeight[pod.Uid]
+// releaseUserPod takes a finished pod, local or outsourced, off its tenant's
+// allocation. It returns false if the pod was not charged to the tenant.
+func (m *Member) releaseUserPod(pod types.Pod) bool {
+	key := podKey(pod)
+	charged, ok := m.usersPods[key]
+	if !ok {
+		return false
+	}
+	delete(m.usersPods, key)
+	res := m.usersAllocatedRes[charged.Uid].Sub(charged.Request())
+	m.usersAllocatedRes[charged.Uid] = res
+	m.computeUserShare(charged.Uid, 0)
+	glog.Infof("Release %s of %s, allocated resource:%v", charged.Name, charged.Uid, res)
+	return true
+}
+
+// computeUserShare divides the tenant's dominant share by its base weight
+// plus the outsourcing adjustment weight returned by the coordinator.
+func (m *Member) computeUserShare(uid string, weight float64) float64 {
+	res := m.usersAllocatedRes[uid]
+	w := m.getUserWeight(uid)
 	w += weight
-	dominantShare := max(float64(res.MilliCpu)/float64(totalCpu), float64(res.Memory)/float64(totalMemory)) / w
-	usersShare[pod.Uid] = dominantShare
+	dominantShare := res.DominantShare(m.getTotalResource()) / w
+	m.usersShare[uid] = dominantShare
+	m.recordTenant(uid)
 	return dominantShare
 }
 
-func getUserShare(uid string) float64 {
-	return usersShare[uid]
+func podKey(pod types.Pod) string {
+	return pod.Uid + "/" + pod.Name
 }
 
-func max(x, y float64) float64 {
-	if x >= y {
-		return x
-	}
-	return y
+func (m *Member) getUserShare(uid string) float64 {
+	return m.usersShare[uid]
 }
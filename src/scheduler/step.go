@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// The member normally runs in the goroutines main starts, which pass pods
+// and records over channels. After Watch it is run by one goroutine calling
+// Sync and Step instead, as the simulator does to get the same results from
+// the same inputs. Nothing blocks then: a pod for a full channel is held
+// back until the channel has room, records are written right away.
+
+// heldPod is a pod for ch that did not fit into it while stepping.
+type heldPod struct {
+	ch  chan types.Pod
+	pod types.Pod
+}
+
+// Watch opens the watches of nodes, namespaces and pods and the event files
+// for Sync and Step. It is called after Start and Connect, in place of the
+// member's goroutines.
+func (m *Member) Watch() error {
+	nodes, err := m.kube.client.CoreV1().Nodes().Watch(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	namespaces, err := m.kube.client.CoreV1().Namespaces().Watch(metav1.ListOptions{})
+	if err != nil {
+		nodes.Stop()
+		return err
+	}
+	pods, err := m.kube.client.CoreV1().Pods("").Watch(metav1.ListOptions{})
+	if err != nil {
+		nodes.Stop()
+		namespaces.Stop()
+		return err
+	}
+	m.watches = []watch.Interface{nodes, namespaces, pods}
+	m.stepping = true
+	m.openSinks()
+	return nil
+}
+
+// Sync handles the events of the watches and what the member's goroutines
+// pass on, without scheduling. It returns once there is nothing left to
+// handle.
+func (m *Member) Sync() {
+	nodes, namespaces, pods := m.watches[0], m.watches[1], m.watches[2]
+	for {
+		m.sendHeld()
+		if event, ok := next(nodes); ok {
+			m.handleNodeEvent(event)
+			continue
+		}
+		if len(m.tenantCh) < cap(m.tenantCh) {
+			if event, ok := next(namespaces); ok {
+				m.handleNamespaceEvent(event)
+				continue
+			}
+		}
+		if event, ok := next(pods); ok {
+			m.handlePodEvent(event)
+			continue
+		}
+		select {
+		case pod := <-m.kube.deletedPodCh:
+			m.freePod(pod)
+			continue
+		default:
+		}
+		if !m.dispatchPending() {
+			return
+		}
+	}
+}
+
+// Step syncs and schedules until ScheduleOnce waits, syncing after every
+// round so that the watches never fill up.
+func (m *Member) Step() {
+	m.Sync()
+	for m.ScheduleOnce() {
+		m.Sync()
+	}
+	m.Sync()
+}
+
+// next returns the next event of w if there is one.
+func next(w watch.Interface) (watch.Event, bool) {
+	select {
+	case event, ok := <-w.ResultChan():
+		return event, ok
+	default:
+		return watch.Event{}, false
+	}
+}
+
+// send sends pod on ch, or holds it back if ch is full while stepping.
+func (m *Member) send(ch chan types.Pod, pod types.Pod) {
+	if !m.stepping {
+		ch <- pod
+		return
+	}
+	m.held = append(m.held, heldPod{ch, pod})
+	m.sendHeld()
+}
+
+// sendHeld sends the held pods whose channel has room, in the order they
+// were held.
+func (m *Member) sendHeld() {
+	full := make(map[chan types.Pod]bool)
+	left := m.held[:0]
+	for _, h := range m.held {
+		if !full[h.ch] && len(h.ch) < cap(h.ch) {
+			h.ch <- h.pod
+			continue
+		}
+		full[h.ch] = true
+		left = append(left, h)
+	}
+	m.held = left
+}
+
+// dispatchPending moves the next pending pod to its tenant's queue, as
+// DispatchPods does. It reports false if there is none or the queue is
+// full, the pod is then dispatched by a later call.
+func (m *Member) dispatchPending() bool {
+	if m.dispatching == nil {
+		select {
+		case pod := <-m.kube.pendingPodCh:
+			if pod.Group != "" {
+				var ok bool
+				if pod, ok = m.collectGroupPod(pod); !ok {
+					return true
+				}
+			}
+			m.dispatching = &pod
+		default:
+			return false
+		}
+	}
+	podsQ := m.usersPodsQ[m.dispatching.Uid]
+	if podsQ != nil && len(podsQ) == cap(podsQ) || len(podsQ) == 0 && len(m.usersActiveQ) == cap(m.usersActiveQ) {
+		return false
+	}
+	m.dispatch(*m.dispatching)
+	m.dispatching = nil
+	return true
+}
@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"types"
+)
+
+// Outsourced pods travel as the JSON encoding of the source pod, since gob
+// drops resource.Quantity values. The receiving cluster always rewrites the
+// metadata (name, namespace, owner references, ...) and the node binding,
+// and then strips the PodSpec fields named in stripFields:
+//
+//	hostNamespaces   hostNetwork, hostPID and hostIPC are turned off
+//	hostPath         hostPath volumes are replaced by emptyDir volumes
+//	privileged       privileged containers and privilege escalation are turned off
+//	serviceAccount   the service account is reset and its token not mounted
+//	imagePullSecrets image pull secrets are dropped, they do not exist here
+//	priorityClass    the priority class is dropped, it may not exist here
+const (
+	outsourceNamespace      = "other-clusters"
+	sourceClusterAnnotation = "federation-scheduler/source-cluster"
+	sourceNameAnnotation    = "federation-scheduler/source-name"
+)
+
+var stripRules = map[string]func(spec *v1.PodSpec){
+	"hostNamespaces":   stripHostNamespaces,
+	"hostPath":         stripHostPath,
+	"privileged":       stripPrivileged,
+	"serviceAccount":   stripServiceAccount,
+	"imagePullSecrets": func(spec *v1.PodSpec) { spec.ImagePullSecrets = nil },
+	"priorityClass": func(spec *v1.PodSpec) {
+		spec.PriorityClassName = ""
+		spec.Priority = nil
+	},
+}
+
+// stripFieldNames lists every field of stripRules, which are all stripped
+// by default.
+func stripFieldNames() []string {
+	names := make([]string, 0, len(stripRules))
+	for name := range stripRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseStripFields(names []string) (map[string]bool, error) {
+	fields := make(map[string]bool)
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, ok := stripRules[name]; !ok {
+			return nil, fmt.Errorf("unknown strip field %q, want one of %v", name, stripFieldNames())
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+func encodePod(pod v1.Pod) []byte {
+	data, err := json.Marshal(pod)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// importPod builds the pod to create here from an outsourced pod, stripping
+// stripFields.
+func importPod(outsourcePod types.OutsourcePod, podName string, stripFields map[string]bool) (*v1.Pod, error) {
+	if len(outsourcePod.PodJson) == 0 {
+		return legacyPod(outsourcePod, podName), nil
+	}
+	var source v1.Pod
+	if err := json.Unmarshal(outsourcePod.PodJson, &source); err != nil {
+		return nil, fmt.Errorf("decode outsourced pod %s: %v", podName, err)
+	}
+	annotations := make(map[string]string)
+	for k, v := range source.Annotations {
+		annotations[k] = v
+	}
+	annotations[sourceClusterAnnotation] = outsourcePod.ClusterId
+	annotations[sourceNameAnnotation] = source.Namespace + "/" + source.Name
+	spec := source.Spec
+	spec.NodeName = ""
+	for name := range stripFields {
+		stripRules[name](&spec)
+	}
+	return &v1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        podName,
+			Namespace:   outsourceNamespace,
+			Labels:      source.Labels,
+			Annotations: annotations,
+		},
+		Spec: spec,
+	}, nil
+}
+
+// legacyPod rebuilds a pod sent by a member that does not send PodJson. Only
+// the basic container fields survive and every container requests the
+// whole pod's resources.
+func legacyPod(outsourcePod types.OutsourcePod, podName string) *v1.Pod {
+	pod := outsourcePod.Pod
+	containers := make([]v1.Container, 0)
+	resourceList := make(map[v1.ResourceName]resource.Quantity)
+	resourceList["cpu"] = *resource.NewMilliQuantity(outsourcePod.MilliCpu, resource.DecimalSI)
+	resourceList["memory"] = *resource.NewQuantity(outsourcePod.Memory*1024*1024, resource.BinarySI)
+	for _, c := range pod.Spec.Containers {
+		container := v1.Container{
+			Name:    c.Name,
+			Image:   c.Image,
+			Command: c.Command,
+			Args:    c.Args,
+			Resources: v1.ResourceRequirements{
+				Requests: resourceList,
+			},
+			ImagePullPolicy: c.ImagePullPolicy,
+		}
+		containers = append(containers, container)
+	}
+	return &v1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: outsourceNamespace,
+		},
+		Spec: v1.PodSpec{
+			SchedulerName: pod.Spec.SchedulerName,
+			RestartPolicy: pod.Spec.RestartPolicy,
+			Containers:    containers,
+		},
+	}
+}
+
+func stripHostNamespaces(spec *v1.PodSpec) {
+	spec.HostNetwork = false
+	spec.HostPID = false
+	spec.HostIPC = false
+}
+
+func stripHostPath(spec *v1.PodSpec) {
+	for i := range spec.Volumes {
+		if spec.Volumes[i].HostPath != nil {
+			spec.Volumes[i].VolumeSource = v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}
+		}
+	}
+}
+
+func stripPrivileged(spec *v1.PodSpec) {
+	off := false
+	strip := func(c *v1.Container) {
+		if c.SecurityContext == nil {
+			return
+		}
+		if c.SecurityContext.Privileged != nil {
+			c.SecurityContext.Privileged = &off
+		}
+		c.SecurityContext.AllowPrivilegeEscalation = &off
+		c.SecurityContext.Capabilities = nil
+	}
+	for i := range spec.InitContainers {
+		strip(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		strip(&spec.Containers[i])
+	}
+}
+
+func stripServiceAccount(spec *v1.PodSpec) {
+	off := false
+	spec.ServiceAccountName = ""
+	spec.DeprecatedServiceAccount = ""
+	spec.AutomountServiceAccountToken = &off
+	// drop the token volume of the source cluster's service account.
+	volumes := spec.Volumes[:0]
+	dropped := make(map[string]bool)
+	for _, vol := range spec.Volumes {
+		if vol.Secret != nil && strings.Contains(vol.Name, "-token-") {
+			dropped[vol.Name] = true
+			continue
+		}
+		volumes = append(volumes, vol)
+	}
+	spec.Volumes = volumes
+	dropMounts := func(c *v1.Container) {
+		mounts := c.VolumeMounts[:0]
+		for _, m := range c.VolumeMounts {
+			if !dropped[m.Name] {
+				mounts = append(mounts, m)
+			}
+		}
+		c.VolumeMounts = mounts
+	}
+	for i := range spec.InitContainers {
+		dropMounts(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		dropMounts(&spec.Containers[i])
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	coordinator "coordinator/scheduler"
+	"errors"
+	"path/filepath"
+	"scheduler"
+	"sort"
+	"time"
+	"types"
+	"types/federationpb"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// coordinatorIdentity is who the coordinator calls members as, members do
+// not check it without TLS.
+const coordinatorIdentity = "coordinator"
+
+var (
+	podsResource = v1.SchemeGroupVersion.WithResource("pods")
+	errQueueFull = errors.New("the coordinator's queue of the cluster is full")
+)
+
+// cluster is a fake Kubernetes cluster run by the member scheduler of
+// src/scheduler. Its API server creates pods, binds them and, once their
+// duration is over, has them succeed.
+type cluster struct {
+	ClusterConfig
+	client *fake.Clientset
+	member *scheduler.Member
+}
+
+// runningPod is a bound pod that succeeds at end.
+type runningPod struct {
+	cluster   *cluster
+	namespace string
+	name      string
+	end       time.Time
+}
+
+func newCluster(config ClusterConfig) (*cluster, error) {
+	c := &cluster{ClusterConfig: config, client: fake.NewSimpleClientset(config.objects()...)}
+	c.client.PrependReactor("create", "pods", c.createPod)
+
+	memberConfig := scheduler.DefaultConfig()
+	memberConfig.ClusterId = config.Id
+	memberConfig.ClientAddress = config.Id
+	memberConfig.Local = config.Local
+	if config.Weight > 0 {
+		memberConfig.Weight = config.Weight
+	}
+	memberConfig.EventsDir = filepath.Join(*outputDir, config.Id)
+	memberConfig.EventsFormat = eventsFormat()
+	memberConfig.EventsSync = "none"
+	for _, name := range []string{"scheduleData", "clusterData", "userData"} {
+		if err := removeEvents(memberConfig.EventsDir, name); err != nil {
+			return nil, err
+		}
+	}
+	m, err := scheduler.NewMember(memberConfig, c.client)
+	if err != nil {
+		return nil, err
+	}
+	m.SetClock(clk)
+	m.SetCoordinatorDialer(func(addr string) (scheduler.CoordinatorConn, error) {
+		return coordinatorConn{}, nil
+	})
+	m.SetMemberDialer(func(id, addr string) (scheduler.MemberConn, error) {
+		dest, ok := clusters[id]
+		if !ok {
+			return nil, errors.New("unknown cluster " + id)
+		}
+		return memberConn{dest.member.Server(config.Id)}, nil
+	})
+	c.member = m
+	return c, nil
+}
+
+// start has the member load the cluster, register and watch it.
+func (c *cluster) start() error {
+	c.member.Start()
+	c.member.Connect()
+	return c.member.Watch()
+}
+
+// createPod creates pods as the API server does and binds them.
+func (c *cluster) createPod(action k8stesting.Action) (bool, runtime.Object, error) {
+	create := action.(k8stesting.CreateAction)
+	if create.GetSubresource() == "binding" {
+		return true, nil, c.bind(create.GetObject().(*v1.Binding))
+	}
+	pod := create.GetObject().(*v1.Pod).DeepCopy()
+	pod.CreationTimestamp = metav1.NewTime(clk.Now())
+	pod.Status.Phase = v1.PodPending
+	return true, pod, c.client.Tracker().Create(podsResource, pod, pod.Namespace)
+}
+
+// bind starts the pod of binding on its node.
+func (c *cluster) bind(binding *v1.Binding) error {
+	obj, err := c.client.Tracker().Get(podsResource, binding.Namespace, binding.Name)
+	if err != nil {
+		return err
+	}
+	pod := obj.(*v1.Pod).DeepCopy()
+	duration, err := time.ParseDuration(pod.Annotations[durationAnnotation])
+	if err != nil {
+		return err
+	}
+	now := clk.Now()
+	start := metav1.NewTime(now)
+	pod.Spec.NodeName = binding.Target.Name
+	pod.Status.Phase = v1.PodRunning
+	pod.Status.StartTime = &start
+	if err := c.client.Tracker().Update(podsResource, pod, pod.Namespace); err != nil {
+		return err
+	}
+	glog.Infof("%s runs %s on %s.", c.Id, pod.Name, pod.Spec.NodeName)
+	running = append(running, runningPod{cluster: c, namespace: pod.Namespace, name: pod.Name, end: now.Add(duration)})
+	sort.SliceStable(running, func(i, j int) bool { return running[i].end.Before(running[j].end) })
+	return nil
+}
+
+// submit creates the pod of j.
+func (c *cluster) submit(j job) {
+	if _, err := c.client.CoreV1().Pods(j.pod.Namespace).Create(j.pod); err != nil {
+		glog.Fatal(err)
+	}
+	c.member.Sync()
+}
+
+// succeed ends a running pod.
+func (c *cluster) succeed(p runningPod) {
+	pod, err := c.client.CoreV1().Pods(p.namespace).Get(p.name, metav1.GetOptions{})
+	if err != nil {
+		glog.Fatal(err)
+	}
+	pod.Status.Phase = v1.PodSucceeded
+	if _, err := c.client.CoreV1().Pods(p.namespace).UpdateStatus(pod); err != nil {
+		glog.Fatal(err)
+	}
+	c.member.Sync()
+}
+
+// coordinatorConn calls the coordinator's scheduler in this process, as its
+// Server does.
+type coordinatorConn struct{}
+
+func (coordinatorConn) RegisterCluster(cluster types.Cluster) (uint32, error) {
+	coordinator.RegisterCluster(cluster)
+	return 0, nil
+}
+
+func (coordinatorConn) Heartbeat(cluster types.Cluster) error {
+	if !coordinator.UpdateCluster(cluster) {
+		return federationpb.NotRegistered(cluster.Id)
+	}
+	return nil
+}
+
+func (coordinatorConn) DeregisterCluster(id string) error {
+	if !coordinator.DeregisterCluster(id) {
+		return federationpb.NotRegistered(id)
+	}
+	return nil
+}
+
+// UploadPod fails if the queue of the pod's cluster is full, where the
+// coordinator's Server would block.
+func (coordinatorConn) UploadPod(pod types.InterPod) (float64, error) {
+	if coordinator.QueueFull(pod.ClusterId) {
+		return 0, errQueueFull
+	}
+	coordinator.QueuePod(pod)
+	return pod.Request().DominantShare(coordinator.TotalResource), nil
+}
+
+func (coordinatorConn) UploadPodGroup(group types.InterPodGroup) (types.GroupReservation, error) {
+	return coordinator.ReserveGroup(group)
+}
+
+func (coordinatorConn) ReleasePod(pod types.InterPod) error {
+	coordinator.ReleasePod(pod)
+	return nil
+}
+
+func (coordinatorConn) ConfirmPlacement(placement types.Placement) error {
+	if dest, ok := coordinator.PlacementDest(placement.InterPod); !ok || dest != placement.DestClusterId {
+		return federationpb.ErrPermissionDenied
+	}
+	return nil
+}
+
+func (coordinatorConn) Reconcile(state types.MemberState) (types.Reconciliation, error) {
+	reconciliation, requeue := coordinator.Reconcile(state)
+	for _, pod := range requeue {
+		coordinator.QueuePod(pod)
+	}
+	return reconciliation, nil
+}
+
+func (coordinatorConn) Close() error {
+	return nil
+}
+
+// memberConn calls another member in this process.
+type memberConn struct {
+	server *scheduler.Server
+}
+
+func (c memberConn) CreatePod(pod types.OutsourcePod) error {
+	var reply int
+	return c.server.CreatePod(&pod, &reply)
+}
+
+func (c memberConn) ReturnScheduleData(data types.ScheduleData) error {
+	var reply int
+	return c.server.ReturnScheduleData(&data, &reply)
+}
+
+func (c memberConn) ReleasePod(pod types.Pod) error {
+	var reply int
+	return c.server.ReleasePod(&pod, &reply)
+}
+
+func (c memberConn) Close() error {
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	mebibyte        = 1024 * 1024
+	defaultNodePods = 110
+	// schedulerName hands the pods of the trace to the member,
+	// weightAnnotation is the member's annotation of a tenant's weight.
+	schedulerName    = "federation-scheduler"
+	weightAnnotation = "federation-scheduler/weight"
+	// durationAnnotation is how long a pod runs once it has a node.
+	durationAnnotation = "simulator/duration"
+)
+
+// ClusterConfig describes one fake cluster of the simulation.
+type ClusterConfig struct {
+	Id string `json:"id"`
+	// Local members never outsource pods, Weight is the cluster's weight
+	// under the weighted-drf fairness policy, 1 if it is not set.
+	Local  bool         `json:"local"`
+	Weight float64      `json:"weight"`
+	Nodes  []NodeConfig `json:"nodes"`
+	// Tenants maps tenant names to their weight, tenants not listed have
+	// weight 1.
+	Tenants map[string]float64 `json:"tenants"`
+}
+
+// NodeConfig describes Count identical nodes. Memory is in MiB.
+type NodeConfig struct {
+	Name     string            `json:"name"`
+	Count    int               `json:"count"`
+	MilliCpu int64             `json:"milliCpu"`
+	Memory   int64             `json:"memory"`
+	Pods     int64             `json:"pods"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// job is one line of the workload trace: a pod of tenant submitted to
+// cluster at arrival, which runs for the duration in its durationAnnotation
+// once it has a node.
+type job struct {
+	pod     *v1.Pod
+	cluster string
+	arrival time.Duration
+}
+
+func loadClusters(path string) ([]ClusterConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var clusters []ClusterConfig
+	if err := json.Unmarshal(data, &clusters); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	seen := make(map[string]bool)
+	for _, c := range clusters {
+		if c.Id == "" {
+			return nil, fmt.Errorf("%s: cluster without id", path)
+		}
+		if seen[c.Id] {
+			return nil, fmt.Errorf("%s: duplicate cluster %s", path, c.Id)
+		}
+		seen[c.Id] = true
+	}
+	return clusters, nil
+}
+
+// objects returns the nodes and the namespaces of the tenants with a weight
+// of the cluster.
+func (c ClusterConfig) objects() []runtime.Object {
+	var objects []runtime.Object
+	for _, n := range c.Nodes {
+		pods := n.Pods
+		if pods == 0 {
+			pods = defaultNodePods
+		}
+		allocatable := v1.ResourceList{
+			v1.ResourceCPU:    *resource.NewMilliQuantity(n.MilliCpu, resource.DecimalSI),
+			v1.ResourceMemory: *resource.NewQuantity(n.Memory*mebibyte, resource.BinarySI),
+			v1.ResourcePods:   *resource.NewQuantity(pods, resource.DecimalSI),
+		}
+		names := []string{n.Name}
+		if n.Count > 1 {
+			names = names[:0]
+			for i := 0; i < n.Count; i++ {
+				names = append(names, n.Name+"-"+strconv.Itoa(i))
+			}
+		}
+		for _, name := range names {
+			objects = append(objects, &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: n.Labels},
+				Status: v1.NodeStatus{
+					Allocatable: allocatable,
+					Conditions:  []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+				},
+			})
+		}
+	}
+	tenants := make([]string, 0, len(c.Tenants))
+	for tenant := range c.Tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		objects = append(objects, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        tenant,
+			Annotations: map[string]string{weightAnnotation: strconv.FormatFloat(c.Tenants[tenant], 'g', -1, 64)},
+		}})
+	}
+	return objects
+}
+
+// loadTrace reads a CSV workload trace with the columns
+//
+//	arrival,tenant,cluster,cpu,memory,duration
+//
+// where arrival and duration are seconds since the start of the simulation,
+// cpu is in millicores and memory in MiB. A header line and lines starting
+// with # are skipped. Jobs are returned in arrival order.
+func loadTrace(path string, clusters map[string]bool) ([]job, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	r := csv.NewReader(fd)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	var jobs []job
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "arrival") {
+			continue
+		}
+		j, err := parseJob(record, len(jobs))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if !clusters[j.cluster] {
+			return nil, fmt.Errorf("%s:%d: unknown cluster %s", path, line, j.cluster)
+		}
+		jobs = append(jobs, j)
+	}
+	sort.SliceStable(jobs, func(i, k int) bool { return jobs[i].arrival < jobs[k].arrival })
+	return jobs, nil
+}
+
+func parseJob(record []string, index int) (job, error) {
+	if len(record) != 6 {
+		return job{}, fmt.Errorf("want 6 fields, got %d", len(record))
+	}
+	arrival, err := parseSeconds(record[0])
+	if err != nil {
+		return job{}, err
+	}
+	cpu, err := strconv.ParseInt(record[3], 10, 64)
+	if err != nil {
+		return job{}, fmt.Errorf("invalid cpu %q", record[3])
+	}
+	memory, err := strconv.ParseInt(record[4], 10, 64)
+	if err != nil {
+		return job{}, fmt.Errorf("invalid memory %q", record[4])
+	}
+	duration, err := parseSeconds(record[5])
+	if err != nil {
+		return job{}, err
+	}
+	tenant := record[1]
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        tenant + "-" + strconv.Itoa(index),
+			Namespace:   tenant,
+			Annotations: map[string]string{durationAnnotation: duration.String()},
+		},
+		Spec: v1.PodSpec{
+			SchedulerName: schedulerName,
+			Containers: []v1.Container{{
+				Name: "main",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:    *resource.NewMilliQuantity(cpu, resource.DecimalSI),
+					v1.ResourceMemory: *resource.NewQuantity(memory*mebibyte, resource.BinarySI),
+				}},
+			}},
+		},
+	}
+	return job{pod: pod, cluster: record[2], arrival: arrival}, nil
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid seconds %q", s)
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
@@ -0,0 +1,179 @@
+// Command simulator replays a workload trace against the coordinator's
+// scheduler and the member scheduler of a set of fake clusters in one
+// process. Time is virtual and every step runs in a fixed order, so the same
+// inputs always produce the same metrics, which makes fairness policies
+// comparable offline:
+//
+//	simulator -clusters clusters.json -trace trace.csv -output ../simulation
+//
+// clusters.json is a list of ClusterConfig, the trace is described at
+// loadTrace. federationData is written to the output directory, the files
+// of each member to a subdirectory named after the cluster.
+package main
+
+import (
+	"clock"
+	coordinator "coordinator/scheduler"
+	"events"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"types"
+
+	"github.com/golang/glog"
+)
+
+// coordinatorPeriod is how often the coordinator's Schedule loop runs, the
+// members run every second.
+const coordinatorPeriod = 3 * time.Second
+
+var (
+	clustersFile   = flag.String("clusters", "clusters.json", "fake clusters of the federation (JSON)")
+	traceFile      = flag.String("trace", "trace.csv", "workload trace: arrival,tenant,cluster,cpu,memory,duration")
+	outputDir      = flag.String("output", "../simulation", "directory the metrics are written to")
+	fairnessPolicy = flag.String("fairness_policy", "contribution-drf", "cluster fairness policy: "+strings.Join(coordinator.FairnessPolicies(), ", "))
+	maxTime        = flag.Duration("max_time", 24*time.Hour, "stop after this much virtual time even if pods are left")
+
+	clk        *clock.Virtual
+	startTime  = time.Unix(0, 0)
+	clusters   map[string]*cluster
+	clusterIds []string
+	running    []runningPod // by end
+	finished   int
+	results    []result
+)
+
+// eventsFormat is the format of the metrics, given by the member
+// scheduler's -events_format.
+func eventsFormat() string {
+	if format := flag.Lookup("events_format").Value.String(); format != "" {
+		return format
+	}
+	return events.FormatCSV
+}
+
+// result is a destination chosen by the coordinator for a pod of source.
+type result struct {
+	types.ScheduleResult
+	source string
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	configs, err := loadClusters(*clustersFile)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	for _, c := range configs {
+		ids[c.Id] = true
+	}
+	trace, err := loadTrace(*traceFile, ids)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	if err := coordinator.SetFairnessPolicy(*fairnessPolicy); err != nil {
+		glog.Fatal(err)
+	}
+
+	clk = clock.NewVirtual(startTime)
+	federationSink, err := createSink(*outputDir, "federationData")
+	if err != nil {
+		glog.Fatal(err)
+	}
+	defer federationSink.Close()
+	coordinator.SetClock(clk)
+	coordinator.SetResultHandler(func(pod types.Pod, source, dest types.Cluster) {
+		results = append(results, result{types.ScheduleResult{Pod: pod, DestIp: dest.Ip, DestPort: dest.Port, DestClusterId: dest.Id}, source.Id})
+	})
+	clusters = make(map[string]*cluster)
+	for _, config := range configs {
+		c, err := newCluster(config)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		defer c.member.Close()
+		clusters[c.Id] = c
+		clusterIds = append(clusterIds, c.Id)
+	}
+	sort.Strings(clusterIds)
+	for _, id := range clusterIds {
+		if err := clusters[id].start(); err != nil {
+			glog.Fatal(err)
+		}
+	}
+
+	next := 0
+	for tick := time.Duration(0); tick <= *maxTime; tick += time.Second {
+		for ; next < len(trace) && trace[next].arrival <= tick; next++ {
+			clusters[trace[next].cluster].submit(trace[next])
+		}
+		finishPods()
+		for _, id := range clusterIds {
+			clusters[id].member.Step()
+		}
+		if tick%coordinatorPeriod == 0 {
+			coordinator.ScheduleOnce()
+			if err := coordinator.FlushClusterData(federationSink); err != nil {
+				glog.Fatal(err)
+			}
+			deliverResults()
+		}
+		if finished == len(trace) {
+			glog.Infof("simulation finished after %v", tick)
+			return
+		}
+		clk.Advance(time.Second)
+	}
+	glog.Warningf("simulation stopped at %v with pods left", *maxTime)
+}
+
+// finishPods has the pods whose duration is over succeed, in the order they
+// end.
+func finishPods() {
+	now := clk.Now()
+	for len(running) > 0 && !running[0].end.After(now) {
+		p := running[0]
+		running = running[1:]
+		p.cluster.succeed(p)
+		finished++
+	}
+}
+
+// deliverResults returns the destinations chosen by the coordinator to the
+// source members, which have the destinations create the pods.
+func deliverResults() {
+	for _, r := range results {
+		var reply int
+		source := clusters[r.source]
+		if err := source.member.Server(coordinatorIdentity).ReturnScheduleResult(&r.ScheduleResult, &reply); err != nil {
+			glog.Warningf("%s did not outsource %s: %v", source.Id, r.Pod.Name, err)
+		}
+		clusters[r.DestClusterId].member.Sync()
+	}
+	results = results[:0]
+}
+
+// createSink opens the event file name in dir, dropping what an earlier run
+// left in it.
+func createSink(dir, name string) (*events.Sink, error) {
+	config := events.Config{Dir: dir, Format: eventsFormat(), SyncInterval: -1, Clock: clk}
+	if err := removeEvents(dir, name); err != nil {
+		return nil, err
+	}
+	return events.Open(name, config)
+}
+
+// removeEvents removes the event file name an earlier run left in dir.
+func removeEvents(dir, name string) error {
+	err := os.Remove(filepath.Join(dir, name+"."+eventsFormat()))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
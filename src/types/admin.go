@@ -0,0 +1,72 @@
+package types
+
+// The types below are the JSON views served by the coordinator's admin API
+// to operators, dashboards and fedctl.
+
+// Quantities are the dimensions of a Resource, see Resource.Dims.
+type Quantities map[string]int64
+
+type ClusterStatus struct {
+	Id            string     `json:"id"`
+	Ip            string     `json:"ip"`
+	Port          string     `json:"port"`
+	Version       uint32     `json:"protocolVersion"`
+	Weight        float64    `json:"weight"`
+	Healthy       bool       `json:"healthy"`
+	Cordoned      bool       `json:"cordoned"`
+	LastHeartbeat int64      `json:"lastHeartbeat"` // unix seconds
+	TotalResource Quantities `json:"totalResource"`
+	Nodes         int        `json:"nodes"`
+	Share         float64    `json:"share"`
+	Allocated     Quantities `json:"allocated"`
+	Contributed   Quantities `json:"contributed"`
+	PendingPods   int        `json:"pendingPods"`
+}
+
+type IdleNodeStatus struct {
+	ClusterId string            `json:"clusterId"`
+	Node      string            `json:"node"`
+	Idle      Quantities        `json:"idle"`
+	Capacity  Quantities        `json:"capacity"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Ledger is what a cluster's pods hold elsewhere and what it gives to pods
+// of other clusters.
+type Ledger struct {
+	Allocated   Quantities `json:"allocated"`
+	Contributed Quantities `json:"contributed"`
+}
+
+// PendingPod is an uploaded pod waiting in its cluster's queue.
+type PendingPod struct {
+	ClusterId string     `json:"clusterId"`
+	Tenant    string     `json:"tenant"`
+	Name      string     `json:"name"`
+	Request   Quantities `json:"request"`
+	QueuedAt  int64      `json:"queuedAt"` // unix seconds
+}
+
+// PlacementRecord is a decision of the coordinator, Group is set for pods
+// reserved as part of a pod group. Node is the idle node the pod fit on,
+// empty if the fairness policy chose the cluster.
+type PlacementRecord struct {
+	Time    int64      `json:"time"` // unix seconds
+	Source  string     `json:"source"`
+	Dest    string     `json:"dest"`
+	Node    string     `json:"node,omitempty"`
+	Tenant  string     `json:"tenant"`
+	Name    string     `json:"name"`
+	Group   string     `json:"group,omitempty"`
+	Request Quantities `json:"request"`
+	Reason  string     `json:"reason"`
+}
+
+// TenantStatus is a tenant of a member as seen by its scheduler.
+type TenantStatus struct {
+	Tenant      string     `json:"tenant"`
+	Share       float64    `json:"share"`
+	Weight      float64    `json:"weight"`
+	Allocated   Quantities `json:"allocated"`
+	PendingPods int        `json:"pendingPods"`
+}
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// NodeConstraints are the parts of a pod spec that restrict which nodes it
+// may run on. Members and the coordinator share this check.
+type NodeConstraints struct {
+	NodeSelector map[string]string
+	// NodeAffinity is the pod's requiredDuringSchedulingIgnoredDuringExecution
+	// node affinity, preferences are not enforced.
+	NodeAffinity *v1.NodeSelector
+	Tolerations  []v1.Toleration
+}
+
+func NewNodeConstraints(spec v1.PodSpec) NodeConstraints {
+	c := NodeConstraints{
+		NodeSelector: spec.NodeSelector,
+		Tolerations:  spec.Tolerations,
+	}
+	if spec.Affinity != nil && spec.Affinity.NodeAffinity != nil {
+		c.NodeAffinity = spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	}
+	return c
+}
+
+// Fits reports whether a pod with these constraints may be placed on node.
+func (c NodeConstraints) Fits(node Node) bool {
+	for k, v := range c.NodeSelector {
+		if node.Labels[k] != v {
+			return false
+		}
+	}
+	if c.NodeAffinity != nil && !matchNodeSelector(c.NodeAffinity, node) {
+		return false
+	}
+	for i := range node.Taints {
+		taint := &node.Taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !c.tolerates(taint) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c NodeConstraints) tolerates(taint *v1.Taint) bool {
+	for i := range c.Tolerations {
+		if c.Tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchNodeSelector ORs the terms of selector, an empty selector matches
+// nothing as in Kubernetes.
+func matchNodeSelector(selector *v1.NodeSelector, node Node) bool {
+	for _, term := range selector.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		if matchRequirements(term.MatchExpressions, node.Labels) &&
+			matchRequirements(term.MatchFields, map[string]string{"metadata.name": node.Name}) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRequirements(reqs []v1.NodeSelectorRequirement, labels map[string]string) bool {
+	for _, req := range reqs {
+		value, ok := labels[req.Key]
+		switch req.Operator {
+		case v1.NodeSelectorOpIn:
+			if !ok || !contains(req.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpNotIn:
+			if ok && contains(req.Values, value) {
+				return false
+			}
+		case v1.NodeSelectorOpExists:
+			if !ok {
+				return false
+			}
+		case v1.NodeSelectorOpDoesNotExist:
+			if ok {
+				return false
+			}
+		case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+			if !ok || len(req.Values) != 1 {
+				return false
+			}
+			have, err1 := strconv.ParseInt(value, 10, 64)
+			want, err2 := strconv.ParseInt(req.Values[0], 10, 64)
+			if err1 != nil || err2 != nil {
+				return false
+			}
+			if req.Operator == v1.NodeSelectorOpGt && have <= want || req.Operator == v1.NodeSelectorOpLt && have >= want {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package federationpb
+
+import (
+	"encoding/json"
+	"types"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// The Encode and Decode functions convert between the shared types and
+// their protobuf messages. Decode functions accept nil messages.
+
+func EncodeResource(r types.Resource) *Resource {
+	return &Resource{Quantities: r.Dims()}
+}
+
+func DecodeResource(r *Resource) types.Resource {
+	if len(r.GetQuantities()) == 0 {
+		return types.Resource{}
+	}
+	quantities := make(map[string]int64, len(r.Quantities))
+	for name, v := range r.Quantities {
+		quantities[name] = v
+	}
+	return types.FromQuantities(quantities)
+}
+
+func EncodeConstraints(c types.NodeConstraints) *NodeConstraints {
+	msg := &NodeConstraints{NodeSelector: c.NodeSelector}
+	if c.NodeAffinity != nil {
+		msg.NodeAffinity = &NodeSelector{}
+		for _, term := range c.NodeAffinity.NodeSelectorTerms {
+			msg.NodeAffinity.Terms = append(msg.NodeAffinity.Terms, &NodeSelectorTerm{
+				MatchExpressions: encodeRequirements(term.MatchExpressions),
+				MatchFields:      encodeRequirements(term.MatchFields),
+			})
+		}
+	}
+	for _, t := range c.Tolerations {
+		msg.Tolerations = append(msg.Tolerations, &Toleration{
+			Key:               t.Key,
+			Operator:          string(t.Operator),
+			Value:             t.Value,
+			Effect:            string(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	return msg
+}
+
+func DecodeConstraints(msg *NodeConstraints) types.NodeConstraints {
+	c := types.NodeConstraints{NodeSelector: msg.GetNodeSelector()}
+	if msg.GetNodeAffinity() != nil {
+		c.NodeAffinity = &v1.NodeSelector{}
+		for _, term := range msg.NodeAffinity.Terms {
+			c.NodeAffinity.NodeSelectorTerms = append(c.NodeAffinity.NodeSelectorTerms, v1.NodeSelectorTerm{
+				MatchExpressions: decodeRequirements(term.MatchExpressions),
+				MatchFields:      decodeRequirements(term.MatchFields),
+			})
+		}
+	}
+	for _, t := range msg.GetTolerations() {
+		c.Tolerations = append(c.Tolerations, v1.Toleration{
+			Key:               t.Key,
+			Operator:          v1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            v1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	return c
+}
+
+func encodeRequirements(reqs []v1.NodeSelectorRequirement) []*NodeSelectorRequirement {
+	msgs := make([]*NodeSelectorRequirement, 0, len(reqs))
+	for _, r := range reqs {
+		msgs = append(msgs, &NodeSelectorRequirement{Key: r.Key, Operator: string(r.Operator), Values: r.Values})
+	}
+	return msgs
+}
+
+func decodeRequirements(msgs []*NodeSelectorRequirement) []v1.NodeSelectorRequirement {
+	if len(msgs) == 0 {
+		return nil
+	}
+	reqs := make([]v1.NodeSelectorRequirement, 0, len(msgs))
+	for _, r := range msgs {
+		reqs = append(reqs, v1.NodeSelectorRequirement{Key: r.Key, Operator: v1.NodeSelectorOperator(r.Operator), Values: r.Values})
+	}
+	return reqs
+}
+
+func EncodePod(p types.Pod) *Pod {
+	return &Pod{
+		Name:          p.Name,
+		Namespace:     p.Uid,
+		NodeName:      p.NodeName,
+		Requests:      EncodeResource(p.Request()),
+		SchedulerName: p.SchedulerName,
+		Constraints:   EncodeConstraints(p.Constraints),
+		Group:         p.Group,
+		MinMember:     int32(p.MinMember),
+	}
+}
+
+func DecodePod(msg *Pod) types.Pod {
+	request := DecodeResource(msg.GetRequests())
+	return types.Pod{
+		Name:            msg.GetName(),
+		Uid:             msg.GetNamespace(),
+		NodeName:        msg.GetNodeName(),
+		RequestMilliCpu: request.MilliCpu,
+		RequestMemory:   request.Memory,
+		Requests:        request,
+		SchedulerName:   msg.GetSchedulerName(),
+		Constraints:     DecodeConstraints(msg.GetConstraints()),
+		Group:           msg.GetGroup(),
+		MinMember:       int(msg.GetMinMember()),
+	}
+}
+
+func EncodePodGroup(g types.PodGroup) *PodGroup {
+	msg := &PodGroup{Name: g.Name, Namespace: g.Uid, MinMember: int32(g.MinMember)}
+	for _, pod := range g.Pods {
+		msg.Pods = append(msg.Pods, EncodePod(pod))
+	}
+	return msg
+}
+
+func DecodePodGroup(msg *PodGroup) types.PodGroup {
+	g := types.PodGroup{Name: msg.GetName(), Uid: msg.GetNamespace(), MinMember: int(msg.GetMinMember())}
+	for _, pod := range msg.GetPods() {
+		g.Pods = append(g.Pods, DecodePod(pod))
+	}
+	return g
+}
+
+func EncodeNode(n types.Node) *Node {
+	msg := &Node{Name: n.Name, Resource: EncodeResource(n.Resource), Labels: n.Labels}
+	for _, t := range n.Taints {
+		msg.Taints = append(msg.Taints, &Taint{Key: t.Key, Value: t.Value, Effect: string(t.Effect)})
+	}
+	return msg
+}
+
+func DecodeNode(msg *Node) types.Node {
+	n := types.Node{Name: msg.GetName(), Resource: DecodeResource(msg.GetResource()), Labels: msg.GetLabels()}
+	for _, t := range msg.GetTaints() {
+		n.Taints = append(n.Taints, v1.Taint{Key: t.Key, Value: t.Value, Effect: v1.TaintEffect(t.Effect)})
+	}
+	return n
+}
+
+func EncodeCluster(c types.Cluster) *Cluster {
+	msg := &Cluster{
+		Id:              c.Id,
+		Ip:              c.Ip,
+		Port:            c.Port,
+		Priority:        c.Priority,
+		TotalResource:   EncodeResource(c.TotalResource),
+		ReportsCapacity: c.ReportsCapacity,
+		Weight:          c.Weight,
+	}
+	for _, node := range c.IdleNodes {
+		msg.IdleNodes = append(msg.IdleNodes, &IdleNode{
+			Node:         EncodeNode(node.Node),
+			IdleResource: EncodeResource(node.IdleResource),
+			ClusterId:    node.ClusterId,
+		})
+	}
+	for _, node := range c.Nodes {
+		msg.Nodes = append(msg.Nodes, EncodeNode(node))
+	}
+	return msg
+}
+
+// DecodeCluster keeps Nodes nil if the member reported none, so the
+// coordinator keeps what it knows.
+func DecodeCluster(msg *Cluster) types.Cluster {
+	c := types.Cluster{
+		Id:              msg.GetId(),
+		Ip:              msg.GetIp(),
+		Port:            msg.GetPort(),
+		Priority:        msg.GetPriority(),
+		TotalResource:   DecodeResource(msg.GetTotalResource()),
+		IdleNodes:       make([]types.InterNode, 0, len(msg.GetIdleNodes())),
+		ReportsCapacity: msg.GetReportsCapacity(),
+		Weight:          msg.GetWeight(),
+	}
+	for _, node := range msg.GetIdleNodes() {
+		c.IdleNodes = append(c.IdleNodes, types.InterNode{
+			Node:         DecodeNode(node.Node),
+			IdleResource: DecodeResource(node.IdleResource),
+			ClusterId:    node.ClusterId,
+		})
+	}
+	for _, node := range msg.GetNodes() {
+		c.Nodes = append(c.Nodes, DecodeNode(node))
+	}
+	return c
+}
+
+func EncodeScheduleResult(r types.ScheduleResult) *ScheduleResult {
+	return &ScheduleResult{Pod: EncodePod(r.Pod), DestIp: r.DestIp, DestPort: r.DestPort, DestVersion: r.DestVersion, DestClusterId: r.DestClusterId}
+}
+
+func DecodeScheduleResult(msg *ScheduleResult) types.ScheduleResult {
+	return types.ScheduleResult{
+		Pod:           DecodePod(msg.GetPod()),
+		DestIp:        msg.GetDestIp(),
+		DestPort:      msg.GetDestPort(),
+		DestVersion:   msg.GetDestVersion(),
+		DestClusterId: msg.GetDestClusterId(),
+	}
+}
+
+func EncodeGroupReservation(r types.GroupReservation) *GroupReservation {
+	msg := &GroupReservation{Weight: r.Weight}
+	for _, result := range r.Results {
+		msg.Results = append(msg.Results, EncodeScheduleResult(result))
+	}
+	return msg
+}
+
+func DecodeGroupReservation(msg *GroupReservation) types.GroupReservation {
+	r := types.GroupReservation{Weight: msg.GetWeight()}
+	for _, result := range msg.GetResults() {
+		r.Results = append(r.Results, DecodeScheduleResult(result))
+	}
+	return r
+}
+
+func EncodeMemberState(state types.MemberState) *MemberState {
+	msg := &MemberState{ClusterId: state.ClusterId}
+	for _, pod := range state.Running {
+		msg.Running = append(msg.Running, &InterPod{ClusterId: pod.ClusterId, Pod: EncodePod(pod.Pod)})
+	}
+	for _, pod := range state.Awaiting {
+		msg.Awaiting = append(msg.Awaiting, EncodePod(pod))
+	}
+	return msg
+}
+
+func DecodeMemberState(msg *MemberState) types.MemberState {
+	state := types.MemberState{ClusterId: msg.GetClusterId()}
+	for _, pod := range msg.GetRunning() {
+		state.Running = append(state.Running, types.InterPod{Pod: DecodePod(pod.GetPod()), ClusterId: pod.GetClusterId()})
+	}
+	for _, pod := range msg.GetAwaiting() {
+		state.Awaiting = append(state.Awaiting, DecodePod(pod))
+	}
+	return state
+}
+
+func EncodeReconciliation(r types.Reconciliation) *Reconciliation {
+	msg := &Reconciliation{Requeued: int32(r.Requeued), Released: int32(r.Released), Charged: int32(r.Charged)}
+	for _, result := range r.Results {
+		msg.Results = append(msg.Results, EncodeScheduleResult(result))
+	}
+	return msg
+}
+
+func DecodeReconciliation(msg *Reconciliation) types.Reconciliation {
+	r := types.Reconciliation{Requeued: int(msg.GetRequeued()), Released: int(msg.GetReleased()), Charged: int(msg.GetCharged())}
+	for _, result := range msg.GetResults() {
+		r.Results = append(r.Results, DecodeScheduleResult(result))
+	}
+	return r
+}
+
+func EncodeScheduleData(d types.ScheduleData) *ScheduleData {
+	return &ScheduleData{Pod: EncodePod(d.Pod), CreateTime: d.CreateTime, StartTime: d.StartTime, Status: d.Status}
+}
+
+func DecodeScheduleData(msg *ScheduleData) types.ScheduleData {
+	return types.ScheduleData{
+		Pod:        DecodePod(msg.GetPod()),
+		CreateTime: msg.GetCreateTime(),
+		StartTime:  msg.GetStartTime(),
+		Status:     msg.GetStatus(),
+	}
+}
+
+// EncodeCreatePod sends the pod as its PodJson only, the gob encoded v1.Pod
+// is a net/rpc leftover.
+func EncodeCreatePod(p types.OutsourcePod) *CreatePodRequest {
+	return &CreatePodRequest{
+		SourceClusterId: p.ClusterId,
+		SourceIp:        p.SourceIP,
+		SourcePort:      p.SourcePort,
+		SourceVersion:   p.SourceVersion,
+		PodJson:         p.PodJson,
+		Resource:        EncodeResource(p.Resource),
+	}
+}
+
+func DecodeCreatePod(msg *CreatePodRequest) (types.OutsourcePod, error) {
+	p := types.OutsourcePod{
+		Resource:      DecodeResource(msg.GetResource()),
+		ClusterId:     msg.GetSourceClusterId(),
+		SourceIP:      msg.GetSourceIp(),
+		SourcePort:    msg.GetSourcePort(),
+		SourceVersion: msg.GetSourceVersion(),
+		PodJson:       msg.GetPodJson(),
+	}
+	if err := json.Unmarshal(p.PodJson, &p.Pod); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func EncodeTenantShares(tenants []types.TenantStatus) *TenantSharesResponse {
+	msg := &TenantSharesResponse{}
+	for _, t := range tenants {
+		msg.Tenants = append(msg.Tenants, &TenantShare{
+			Tenant:      t.Tenant,
+			Share:       t.Share,
+			Weight:      t.Weight,
+			Allocated:   &Resource{Quantities: t.Allocated},
+			PendingPods: int32(t.PendingPods),
+		})
+	}
+	return msg
+}
+
+func DecodeTenantShares(msg *TenantSharesResponse) []types.TenantStatus {
+	tenants := make([]types.TenantStatus, 0, len(msg.GetTenants()))
+	for _, t := range msg.GetTenants() {
+		tenants = append(tenants, types.TenantStatus{
+			Tenant:      t.Tenant,
+			Share:       t.Share,
+			Weight:      t.Weight,
+			Allocated:   t.GetAllocated().GetQuantities(),
+			PendingPods: int(t.PendingPods),
+		})
+	}
+	return tenants
+}
@@ -0,0 +1,772 @@
+// Protocol between the federation coordinator and its member clusters.
+//
+// Members negotiate the protocol version in RegisterCluster. Members that
+// predate this protocol keep using net/rpc and are treated as version 0.
+// Regenerate the Go code with:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative federation.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: federation.proto
+
+package federationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Coordinator_RegisterCluster_FullMethodName   = "/federation.v1.Coordinator/RegisterCluster"
+	Coordinator_Heartbeat_FullMethodName         = "/federation.v1.Coordinator/Heartbeat"
+	Coordinator_DeregisterCluster_FullMethodName = "/federation.v1.Coordinator/DeregisterCluster"
+	Coordinator_UploadPod_FullMethodName         = "/federation.v1.Coordinator/UploadPod"
+	Coordinator_UploadPodGroup_FullMethodName    = "/federation.v1.Coordinator/UploadPodGroup"
+	Coordinator_ReleasePod_FullMethodName        = "/federation.v1.Coordinator/ReleasePod"
+	Coordinator_ConfirmPlacement_FullMethodName  = "/federation.v1.Coordinator/ConfirmPlacement"
+	Coordinator_CordonCluster_FullMethodName     = "/federation.v1.Coordinator/CordonCluster"
+	Coordinator_Reconcile_FullMethodName         = "/federation.v1.Coordinator/Reconcile"
+	Coordinator_Replicate_FullMethodName         = "/federation.v1.Coordinator/Replicate"
+)
+
+// CoordinatorClient is the client API for Coordinator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Coordinator is served by the coordinator.
+type CoordinatorClient interface {
+	// RegisterCluster joins a cluster and agrees on a protocol version.
+	RegisterCluster(ctx context.Context, in *RegisterClusterRequest, opts ...grpc.CallOption) (*RegisterClusterResponse, error)
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*Empty, error)
+	DeregisterCluster(ctx context.Context, in *DeregisterClusterRequest, opts ...grpc.CallOption) (*Empty, error)
+	// UploadPod queues a pod its cluster has no room for, the response is
+	// the weight the member adds to the tenant's share.
+	UploadPod(ctx context.Context, in *UploadPodRequest, opts ...grpc.CallOption) (*UploadPodResponse, error)
+	UploadPodGroup(ctx context.Context, in *UploadPodGroupRequest, opts ...grpc.CallOption) (*GroupReservation, error)
+	ReleasePod(ctx context.Context, in *ReleasePodRequest, opts ...grpc.CallOption) (*Empty, error)
+	// ConfirmPlacement fails unless the coordinator placed the pod on
+	// dest_cluster_id, which calls it before creating the pod.
+	ConfirmPlacement(ctx context.Context, in *ConfirmPlacementRequest, opts ...grpc.CallOption) (*Empty, error)
+	// CordonCluster keeps, or with cordoned false stops keeping, pods of
+	// other clusters off a cluster. It is called by operators.
+	CordonCluster(ctx context.Context, in *CordonClusterRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Reconcile repairs the coordinator's ledgers with what a member runs
+	// and waits for, e.g. after the coordinator restarted. Members call it
+	// after registering and then periodically.
+	Reconcile(ctx context.Context, in *MemberState, opts ...grpc.CallOption) (*Reconciliation, error)
+	// Replicate is called by the other coordinator replicas on the leader.
+	// Replicas that do not lead answer members with the address of the one
+	// that does.
+	Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (*ReplicateResponse, error)
+}
+
+type coordinatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCoordinatorClient(cc grpc.ClientConnInterface) CoordinatorClient {
+	return &coordinatorClient{cc}
+}
+
+func (c *coordinatorClient) RegisterCluster(ctx context.Context, in *RegisterClusterRequest, opts ...grpc.CallOption) (*RegisterClusterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterClusterResponse)
+	err := c.cc.Invoke(ctx, Coordinator_RegisterCluster_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Coordinator_Heartbeat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) DeregisterCluster(ctx context.Context, in *DeregisterClusterRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Coordinator_DeregisterCluster_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) UploadPod(ctx context.Context, in *UploadPodRequest, opts ...grpc.CallOption) (*UploadPodResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadPodResponse)
+	err := c.cc.Invoke(ctx, Coordinator_UploadPod_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) UploadPodGroup(ctx context.Context, in *UploadPodGroupRequest, opts ...grpc.CallOption) (*GroupReservation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GroupReservation)
+	err := c.cc.Invoke(ctx, Coordinator_UploadPodGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) ReleasePod(ctx context.Context, in *ReleasePodRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Coordinator_ReleasePod_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) ConfirmPlacement(ctx context.Context, in *ConfirmPlacementRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Coordinator_ConfirmPlacement_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) CordonCluster(ctx context.Context, in *CordonClusterRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Coordinator_CordonCluster_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) Reconcile(ctx context.Context, in *MemberState, opts ...grpc.CallOption) (*Reconciliation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Reconciliation)
+	err := c.cc.Invoke(ctx, Coordinator_Reconcile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (*ReplicateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplicateResponse)
+	err := c.cc.Invoke(ctx, Coordinator_Replicate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CoordinatorServer is the server API for Coordinator service.
+// All implementations must embed UnimplementedCoordinatorServer
+// for forward compatibility.
+//
+// Coordinator is served by the coordinator.
+type CoordinatorServer interface {
+	// RegisterCluster joins a cluster and agrees on a protocol version.
+	RegisterCluster(context.Context, *RegisterClusterRequest) (*RegisterClusterResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*Empty, error)
+	DeregisterCluster(context.Context, *DeregisterClusterRequest) (*Empty, error)
+	// UploadPod queues a pod its cluster has no room for, the response is
+	// the weight the member adds to the tenant's share.
+	UploadPod(context.Context, *UploadPodRequest) (*UploadPodResponse, error)
+	UploadPodGroup(context.Context, *UploadPodGroupRequest) (*GroupReservation, error)
+	ReleasePod(context.Context, *ReleasePodRequest) (*Empty, error)
+	// ConfirmPlacement fails unless the coordinator placed the pod on
+	// dest_cluster_id, which calls it before creating the pod.
+	ConfirmPlacement(context.Context, *ConfirmPlacementRequest) (*Empty, error)
+	// CordonCluster keeps, or with cordoned false stops keeping, pods of
+	// other clusters off a cluster. It is called by operators.
+	CordonCluster(context.Context, *CordonClusterRequest) (*Empty, error)
+	// Reconcile repairs the coordinator's ledgers with what a member runs
+	// and waits for, e.g. after the coordinator restarted. Members call it
+	// after registering and then periodically.
+	Reconcile(context.Context, *MemberState) (*Reconciliation, error)
+	// Replicate is called by the other coordinator replicas on the leader.
+	// Replicas that do not lead answer members with the address of the one
+	// that does.
+	Replicate(context.Context, *ReplicateRequest) (*ReplicateResponse, error)
+	mustEmbedUnimplementedCoordinatorServer()
+}
+
+// UnimplementedCoordinatorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCoordinatorServer struct{}
+
+func (UnimplementedCoordinatorServer) RegisterCluster(context.Context, *RegisterClusterRequest) (*RegisterClusterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterCluster not implemented")
+}
+func (UnimplementedCoordinatorServer) Heartbeat(context.Context, *HeartbeatRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedCoordinatorServer) DeregisterCluster(context.Context, *DeregisterClusterRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeregisterCluster not implemented")
+}
+func (UnimplementedCoordinatorServer) UploadPod(context.Context, *UploadPodRequest) (*UploadPodResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadPod not implemented")
+}
+func (UnimplementedCoordinatorServer) UploadPodGroup(context.Context, *UploadPodGroupRequest) (*GroupReservation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadPodGroup not implemented")
+}
+func (UnimplementedCoordinatorServer) ReleasePod(context.Context, *ReleasePodRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleasePod not implemented")
+}
+func (UnimplementedCoordinatorServer) ConfirmPlacement(context.Context, *ConfirmPlacementRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmPlacement not implemented")
+}
+func (UnimplementedCoordinatorServer) CordonCluster(context.Context, *CordonClusterRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CordonCluster not implemented")
+}
+func (UnimplementedCoordinatorServer) Reconcile(context.Context, *MemberState) (*Reconciliation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reconcile not implemented")
+}
+func (UnimplementedCoordinatorServer) Replicate(context.Context, *ReplicateRequest) (*ReplicateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
+func (UnimplementedCoordinatorServer) mustEmbedUnimplementedCoordinatorServer() {}
+func (UnimplementedCoordinatorServer) testEmbeddedByValue()                     {}
+
+// UnsafeCoordinatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CoordinatorServer will
+// result in compilation errors.
+type UnsafeCoordinatorServer interface {
+	mustEmbedUnimplementedCoordinatorServer()
+}
+
+func RegisterCoordinatorServer(s grpc.ServiceRegistrar, srv CoordinatorServer) {
+	// If the following call pancis, it indicates UnimplementedCoordinatorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Coordinator_ServiceDesc, srv)
+}
+
+func _Coordinator_RegisterCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).RegisterCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_RegisterCluster_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).RegisterCluster(ctx, req.(*RegisterClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_DeregisterCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).DeregisterCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_DeregisterCluster_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).DeregisterCluster(ctx, req.(*DeregisterClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_UploadPod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadPodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).UploadPod(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_UploadPod_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).UploadPod(ctx, req.(*UploadPodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_UploadPodGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadPodGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).UploadPodGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_UploadPodGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).UploadPodGroup(ctx, req.(*UploadPodGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_ReleasePod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleasePodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).ReleasePod(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_ReleasePod_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).ReleasePod(ctx, req.(*ReleasePodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_ConfirmPlacement_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmPlacementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).ConfirmPlacement(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_ConfirmPlacement_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).ConfirmPlacement(ctx, req.(*ConfirmPlacementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_CordonCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CordonClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).CordonCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_CordonCluster_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).CordonCluster(ctx, req.(*CordonClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_Reconcile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MemberState)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).Reconcile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_Reconcile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).Reconcile(ctx, req.(*MemberState))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_Replicate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).Replicate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_Replicate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).Replicate(ctx, req.(*ReplicateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Coordinator_ServiceDesc is the grpc.ServiceDesc for Coordinator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Coordinator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "federation.v1.Coordinator",
+	HandlerType: (*CoordinatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterCluster",
+			Handler:    _Coordinator_RegisterCluster_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _Coordinator_Heartbeat_Handler,
+		},
+		{
+			MethodName: "DeregisterCluster",
+			Handler:    _Coordinator_DeregisterCluster_Handler,
+		},
+		{
+			MethodName: "UploadPod",
+			Handler:    _Coordinator_UploadPod_Handler,
+		},
+		{
+			MethodName: "UploadPodGroup",
+			Handler:    _Coordinator_UploadPodGroup_Handler,
+		},
+		{
+			MethodName: "ReleasePod",
+			Handler:    _Coordinator_ReleasePod_Handler,
+		},
+		{
+			MethodName: "ConfirmPlacement",
+			Handler:    _Coordinator_ConfirmPlacement_Handler,
+		},
+		{
+			MethodName: "CordonCluster",
+			Handler:    _Coordinator_CordonCluster_Handler,
+		},
+		{
+			MethodName: "Reconcile",
+			Handler:    _Coordinator_Reconcile_Handler,
+		},
+		{
+			MethodName: "Replicate",
+			Handler:    _Coordinator_Replicate_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "federation.proto",
+}
+
+const (
+	Member_ReturnScheduleResult_FullMethodName = "/federation.v1.Member/ReturnScheduleResult"
+	Member_CreatePod_FullMethodName            = "/federation.v1.Member/CreatePod"
+	Member_ReturnScheduleData_FullMethodName   = "/federation.v1.Member/ReturnScheduleData"
+	Member_ReleasePod_FullMethodName           = "/federation.v1.Member/ReleasePod"
+	Member_TenantShares_FullMethodName         = "/federation.v1.Member/TenantShares"
+)
+
+// MemberClient is the client API for Member service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Member is served by every member cluster.
+type MemberClient interface {
+	// ReturnScheduleResult is called by the coordinator on the source cluster
+	// of a pod it has placed.
+	ReturnScheduleResult(ctx context.Context, in *ScheduleResult, opts ...grpc.CallOption) (*Empty, error)
+	// CreatePod is called by the source cluster on the destination cluster.
+	CreatePod(ctx context.Context, in *CreatePodRequest, opts ...grpc.CallOption) (*Empty, error)
+	// ReturnScheduleData and ReleasePod are called by the destination cluster
+	// on the source cluster once the pod has finished.
+	ReturnScheduleData(ctx context.Context, in *ScheduleData, opts ...grpc.CallOption) (*Empty, error)
+	ReleasePod(ctx context.Context, in *Pod, opts ...grpc.CallOption) (*Empty, error)
+	// TenantShares is called by operators.
+	TenantShares(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*TenantSharesResponse, error)
+}
+
+type memberClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMemberClient(cc grpc.ClientConnInterface) MemberClient {
+	return &memberClient{cc}
+}
+
+func (c *memberClient) ReturnScheduleResult(ctx context.Context, in *ScheduleResult, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Member_ReturnScheduleResult_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memberClient) CreatePod(ctx context.Context, in *CreatePodRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Member_CreatePod_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memberClient) ReturnScheduleData(ctx context.Context, in *ScheduleData, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Member_ReturnScheduleData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memberClient) ReleasePod(ctx context.Context, in *Pod, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Member_ReleasePod_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *memberClient) TenantShares(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*TenantSharesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TenantSharesResponse)
+	err := c.cc.Invoke(ctx, Member_TenantShares_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MemberServer is the server API for Member service.
+// All implementations must embed UnimplementedMemberServer
+// for forward compatibility.
+//
+// Member is served by every member cluster.
+type MemberServer interface {
+	// ReturnScheduleResult is called by the coordinator on the source cluster
+	// of a pod it has placed.
+	ReturnScheduleResult(context.Context, *ScheduleResult) (*Empty, error)
+	// CreatePod is called by the source cluster on the destination cluster.
+	CreatePod(context.Context, *CreatePodRequest) (*Empty, error)
+	// ReturnScheduleData and ReleasePod are called by the destination cluster
+	// on the source cluster once the pod has finished.
+	ReturnScheduleData(context.Context, *ScheduleData) (*Empty, error)
+	ReleasePod(context.Context, *Pod) (*Empty, error)
+	// TenantShares is called by operators.
+	TenantShares(context.Context, *Empty) (*TenantSharesResponse, error)
+	mustEmbedUnimplementedMemberServer()
+}
+
+// UnimplementedMemberServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMemberServer struct{}
+
+func (UnimplementedMemberServer) ReturnScheduleResult(context.Context, *ScheduleResult) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReturnScheduleResult not implemented")
+}
+func (UnimplementedMemberServer) CreatePod(context.Context, *CreatePodRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePod not implemented")
+}
+func (UnimplementedMemberServer) ReturnScheduleData(context.Context, *ScheduleData) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReturnScheduleData not implemented")
+}
+func (UnimplementedMemberServer) ReleasePod(context.Context, *Pod) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleasePod not implemented")
+}
+func (UnimplementedMemberServer) TenantShares(context.Context, *Empty) (*TenantSharesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TenantShares not implemented")
+}
+func (UnimplementedMemberServer) mustEmbedUnimplementedMemberServer() {}
+func (UnimplementedMemberServer) testEmbeddedByValue()                {}
+
+// UnsafeMemberServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MemberServer will
+// result in compilation errors.
+type UnsafeMemberServer interface {
+	mustEmbedUnimplementedMemberServer()
+}
+
+func RegisterMemberServer(s grpc.ServiceRegistrar, srv MemberServer) {
+	// If the following call pancis, it indicates UnimplementedMemberServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Member_ServiceDesc, srv)
+}
+
+func _Member_ReturnScheduleResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleResult)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemberServer).ReturnScheduleResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Member_ReturnScheduleResult_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemberServer).ReturnScheduleResult(ctx, req.(*ScheduleResult))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Member_CreatePod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemberServer).CreatePod(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Member_CreatePod_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemberServer).CreatePod(ctx, req.(*CreatePodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Member_ReturnScheduleData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleData)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemberServer).ReturnScheduleData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Member_ReturnScheduleData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemberServer).ReturnScheduleData(ctx, req.(*ScheduleData))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Member_ReleasePod_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Pod)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemberServer).ReleasePod(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Member_ReleasePod_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemberServer).ReleasePod(ctx, req.(*Pod))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Member_TenantShares_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MemberServer).TenantShares(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Member_TenantShares_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MemberServer).TenantShares(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Member_ServiceDesc is the grpc.ServiceDesc for Member service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Member_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "federation.v1.Member",
+	HandlerType: (*MemberServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReturnScheduleResult",
+			Handler:    _Member_ReturnScheduleResult_Handler,
+		},
+		{
+			MethodName: "CreatePod",
+			Handler:    _Member_CreatePod_Handler,
+		},
+		{
+			MethodName: "ReturnScheduleData",
+			Handler:    _Member_ReturnScheduleData_Handler,
+		},
+		{
+			MethodName: "ReleasePod",
+			Handler:    _Member_ReleasePod_Handler,
+		},
+		{
+			MethodName: "TenantShares",
+			Handler:    _Member_TenantShares_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "federation.proto",
+}
@@ -0,0 +1,74 @@
+package federationpb
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// notLeader starts the error of coordinator replicas that do not lead,
+	// followed by the address of the leader if they know it.
+	notLeader = "not the leader coordinator, leader: "
+	// notRegistered starts the error of a coordinator called by a cluster
+	// it does not know, followed by the id of the cluster.
+	notRegistered = "cluster is not registered: "
+)
+
+// NotLeader is returned by a coordinator replica that does not lead to
+// members calling it, leader is the address of the one that does, empty
+// while an election is under way.
+func NotLeader(leader string) error {
+	return errors.New(notLeader + leader)
+}
+
+// LeaderOf tells whether err is a NotLeader error, of net/rpc or gRPC, and
+// the leader it names.
+func LeaderOf(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	msg := message(err)
+	if !strings.HasPrefix(msg, notLeader) {
+		return "", false
+	}
+	return strings.TrimPrefix(msg, notLeader), true
+}
+
+// NotRegistered is returned by the coordinator to a cluster that calls it
+// without being registered, as after the coordinator lost its state.
+func NotRegistered(clusterId string) error {
+	return errors.New(notRegistered + clusterId)
+}
+
+// IsNotRegistered tells whether err is a NotRegistered error, of net/rpc or
+// gRPC.
+func IsNotRegistered(err error) bool {
+	return err != nil && strings.HasPrefix(message(err), notRegistered)
+}
+
+// message is the text of err, without the code of a gRPC status.
+func message(err error) string {
+	if s, ok := status.FromError(err); ok {
+		return s.Message()
+	}
+	return err.Error()
+}
+
+// Unreachable tells whether err means the callee could not be reached or
+// went away, as opposed to refusing the call.
+func Unreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if s, ok := status.FromError(err); ok && s.Code() == codes.Unavailable {
+		return true
+	}
+	var netErr net.Error
+	return err == rpc.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF || errors.As(err, &netErr)
+}
@@ -0,0 +1,49 @@
+package federationpb
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Handler serves gRPC requests with srv and hands everything else, such as
+// net/rpc, to fallback, so that both protocols share one port during the
+// migration.
+func Handler(srv *grpc.Server, fallback http.Handler) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			srv.ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	}), &http2.Server{})
+}
+
+// Serve serves handler on listener, over TLS if config is not nil. gRPC
+// callers get HTTP/2 by ALPN then, net/rpc callers HTTP/1.1.
+func Serve(listener net.Listener, handler http.Handler, config *tls.Config) error {
+	if config == nil {
+		return http.Serve(listener, handler)
+	}
+	srv := &http.Server{Handler: handler, TLSConfig: config.Clone()}
+	if err := http2.ConfigureServer(srv, nil); err != nil {
+		return err
+	}
+	return srv.Serve(tls.NewListener(listener, srv.TLSConfig))
+}
+
+// Dial connects to a gRPC peer, over TLS if config is not nil. The
+// connection is established lazily, so errors show up on the first call.
+func Dial(addr string, config *tls.Config) (*grpc.ClientConn, error) {
+	if config == nil {
+		return grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	return grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+}
@@ -0,0 +1,152 @@
+package federationpb
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/rpc"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// CoordinatorIdentity is the identity the coordinator's certificate
+	// carries unless configured otherwise.
+	CoordinatorIdentity = "coordinator"
+	// OperatorIdentity is the default identity of the certificate fedctl
+	// calls with, the only one allowed to cordon clusters and read tenant
+	// shares.
+	OperatorIdentity = "fedctl"
+)
+
+// ErrPermissionDenied is returned by handlers whose caller is not the
+// cluster the request speaks for.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// LoadTLS reads a certificate, its key and the CA that signs every member
+// and the coordinator. The config verifies peers in both directions, the
+// server name of a client is set per call with WithServerName. An empty
+// certFile disables TLS and returns nil.
+func LoadTLS(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, fmt.Errorf("tls needs a certificate, a key and a CA")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no CA certificate", caFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// WithServerName returns a copy of config that only accepts a server whose
+// certificate is issued to identity. It returns nil for a nil config.
+func WithServerName(config *tls.Config, identity string) *tls.Config {
+	if config == nil {
+		return nil
+	}
+	c := config.Clone()
+	c.ServerName = identity
+	return c
+}
+
+// Identity is the cluster id a certificate is issued to: its first DNS
+// name, or its common name if it has none.
+func Identity(cert *x509.Certificate) string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
+
+// StateIdentity is the identity of the verified peer of a TLS connection,
+// "" without TLS.
+func StateIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	return Identity(state.PeerCertificates[0])
+}
+
+// CallerIdentity is the identity of the verified caller of a gRPC call, ""
+// without TLS.
+func CallerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	return StateIdentity(&info.State)
+}
+
+// RPCHandler serves net/rpc like rpc.HandleHTTP, but with a server built by
+// newServer for each connection, so that the handlers know the identity of
+// their caller.
+func RPCHandler(newServer func(caller string) *rpc.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			io.WriteString(w, "405 must CONNECT\n")
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, "HTTP/1.0 200 Connected to Go RPC\n\n")
+		newServer(StateIdentity(r.TLS)).ServeConn(conn)
+	})
+}
+
+// DialRPC connects to a net/rpc server like rpc.DialHTTP, over TLS if config
+// is not nil.
+func DialRPC(addr string, config *tls.Config) (*rpc.Client, error) {
+	if config == nil {
+		return rpc.DialHTTP("tcp", addr)
+	}
+	c := config.Clone()
+	c.NextProtos = []string{"http/1.1"}
+	conn, err := tls.Dial("tcp", addr, c)
+	if err != nil {
+		return nil, err
+	}
+	io.WriteString(conn, "CONNECT "+rpc.DefaultRPCPath+" HTTP/1.0\n\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.Status != "200 Connected to Go RPC" {
+		err = errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, &net.OpError{Op: "dial-http", Net: "tcp " + addr, Addr: nil, Err: err}
+	}
+	return rpc.NewClient(conn), nil
+}
@@ -0,0 +1,25 @@
+package federationpb
+
+// The range of protocol versions this build speaks. Version 0 stands for
+// members that still talk net/rpc.
+const (
+	MinProtocolVersion uint32 = 1
+	ProtocolVersion    uint32 = 2
+)
+
+// ConfirmPlacementVersion is the first version whose coordinator answers
+// ConfirmPlacement.
+const ConfirmPlacementVersion uint32 = 2
+
+// Negotiate picks the newest version in both [min, max] and the range this
+// build speaks, or reports false if they do not overlap.
+func Negotiate(min, max uint32) (uint32, bool) {
+	version := max
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+	if version < min || version < MinProtocolVersion {
+		return 0, false
+	}
+	return version, true
+}
@@ -14,9 +14,22 @@ type Cluster struct {
 	Priority         float64
 	index            int
 	Ip               string
+	Port             string
 	ContributedShare float64
 	TotalResource    Resource
 	IdleNodes        []InterNode
+	Nodes            []Node // every schedulable node, for placement constraints
+	LastHeartbeat    int64
+	Healthy          bool
+	// Version is the protocol version agreed at registration, 0 for members
+	// that talk net/rpc.
+	Version uint32
+	// Weight is the weight the member registers the cluster with under the
+	// weighted-drf fairness policy, 0 counts as 1. Priority is its share.
+	Weight float64
+	// ReportsCapacity is set in heartbeats of members that report their
+	// TotalResource, older members leave it empty.
+	ReportsCapacity bool
 }
 
 type InterNode struct {
@@ -67,13 +80,65 @@ func (c ClusterSlice) Less(i, j int) bool {
 type OutsourcePod struct {
 	v1.Pod
 	Resource
+	ClusterId  string
+	SourceIP   string
+	SourcePort string
+	// PodJson is the JSON encoding of Pod. Unlike the gob encoded Pod it
+	// keeps resource quantities, so the receiver can recreate the full spec.
+	PodJson []byte
+	// SourceVersion is the protocol version the source cluster is called with.
+	SourceVersion uint32
+}
+
+type Placement struct {
+	InterPod
+	DestClusterId string
+	Time          int64
+}
+
+// Cordon keeps pods of other clusters off ClusterId, or stops doing so.
+type Cordon struct {
+	ClusterId string
+	Cordoned  bool
+}
+
+type InterPodGroup struct {
+	PodGroup
 	ClusterId string
-	SourceIP  string
+}
+
+// GroupReservation answers an InterPodGroup: every pod has been reserved a
+// destination before any is created.
+type GroupReservation struct {
+	Results []ScheduleResult
+	Weight  float64
 }
 
 type ScheduleResult struct {
 	Pod
-	DestIp string
+	DestIp      string
+	DestPort    string
+	DestVersion uint32 // protocol version of the destination cluster
+	// DestClusterId is checked against the destination's certificate.
+	DestClusterId string
+}
+
+// MemberState is what a member reports to the coordinator to reconcile its
+// ledgers: the pods of other clusters it runs, and its own uploaded pods
+// that have waited a while for a result.
+type MemberState struct {
+	ClusterId string
+	Running   []InterPod
+	Awaiting  []Pod
+}
+
+// Reconciliation answers a MemberState. Results are the lost results of
+// awaited pods that had been placed, the counts what else was repaired.
+type Reconciliation struct {
+	Results  []ScheduleResult
+	Requeued int
+	Released int
+	Charged  int
 }
 
 type ScheduleData struct {
@@ -0,0 +1,165 @@
+package types
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	ResourceCpu    = string(v1.ResourceCPU)    // in millicores
+	ResourceMemory = string(v1.ResourceMemory) // in bytes
+	ResourcePods   = string(v1.ResourcePods)
+
+	mebibyte = 1024 * 1024
+)
+
+// NewResource converts a Kubernetes resource list. Cpu is kept in millicores,
+// every other dimension in its base unit.
+func NewResource(list v1.ResourceList) Resource {
+	quantities := make(map[string]int64, len(list))
+	for name, q := range list {
+		if name == v1.ResourceCPU {
+			quantities[ResourceCpu] = q.MilliValue()
+		} else {
+			quantities[string(name)] = q.Value()
+		}
+	}
+	return FromQuantities(quantities)
+}
+
+// FromQuantities builds a Resource with the legacy fields kept in sync, so
+// members that only read MilliCpu and Memory still see the right values.
+func FromQuantities(quantities map[string]int64) Resource {
+	return Resource{
+		MilliCpu:   quantities[ResourceCpu],
+		Memory:     quantities[ResourceMemory] / mebibyte,
+		Quantities: quantities,
+	}
+}
+
+// Dims returns a copy of every dimension of r. A Resource from a member that
+// predates Quantities only has cpu and memory.
+func (r Resource) Dims() map[string]int64 {
+	dims := make(map[string]int64, len(r.Quantities)+2)
+	if r.Quantities == nil {
+		if r.MilliCpu != 0 {
+			dims[ResourceCpu] = r.MilliCpu
+		}
+		if r.Memory != 0 {
+			dims[ResourceMemory] = r.Memory * mebibyte
+		}
+		return dims
+	}
+	for name, v := range r.Quantities {
+		dims[name] = v
+	}
+	return dims
+}
+
+// Get returns one dimension of r.
+func (r Resource) Get(name string) int64 {
+	if r.Quantities == nil {
+		return r.Dims()[name]
+	}
+	return r.Quantities[name]
+}
+
+func (r Resource) Add(o Resource) Resource {
+	dims := r.Dims()
+	for name, v := range o.Dims() {
+		dims[name] += v
+	}
+	return FromQuantities(dims)
+}
+
+func (r Resource) Sub(o Resource) Resource {
+	dims := r.Dims()
+	for name, v := range o.Dims() {
+		dims[name] -= v
+	}
+	return FromQuantities(dims)
+}
+
+func (r Resource) IsZero() bool {
+	for _, v := range r.Dims() {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Resource) Equal(o Resource) bool {
+	return r.Sub(o).IsZero()
+}
+
+// Fits reports whether r fits into capacity in every dimension. A capacity
+// reported by a member that predates Quantities is only checked for cpu
+// and memory.
+func (r Resource) Fits(capacity Resource) bool {
+	legacy := capacity.Quantities == nil
+	capDims := capacity.Dims()
+	for name, v := range r.Dims() {
+		if legacy && name != ResourceCpu && name != ResourceMemory {
+			continue
+		}
+		if v > capDims[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// DominantShare is the largest fraction of total that r takes in any
+// dimension present in both.
+func (r Resource) DominantShare(total Resource) float64 {
+	totalDims := total.Dims()
+	var share float64
+	for name, v := range r.Dims() {
+		if totalDims[name] <= 0 {
+			continue
+		}
+		if s := float64(v) / float64(totalDims[name]); s > share {
+			share = s
+		}
+	}
+	return share
+}
+
+// Fraction returns the fraction of total that r takes in one dimension.
+func (r Resource) Fraction(name string, total Resource) (float64, bool) {
+	t := total.Get(name)
+	if t <= 0 {
+		return 0, false
+	}
+	return float64(r.Get(name)) / float64(t), true
+}
+
+// Request returns what the pod asks for, falling back to the legacy fields
+// for pods sent by members that predate Requests.
+func (p Pod) Request() Resource {
+	if p.Requests.Quantities != nil {
+		return p.Requests
+	}
+	return Resource{MilliCpu: p.RequestMilliCpu, Memory: p.RequestMemory}
+}
+
+// NewPodRequest sums the container requests of a pod spec the way
+// Kubernetes does: init containers run one at a time, so only the largest
+// counts per dimension. Every pod also takes one of the node's pod slots.
+func NewPodRequest(spec v1.PodSpec) Resource {
+	dims := make(map[string]int64)
+	for _, ctn := range spec.Containers {
+		for name, v := range NewResource(ctn.Resources.Requests).Quantities {
+			dims[name] += v
+		}
+	}
+	for _, ctn := range spec.InitContainers {
+		for name, v := range NewResource(ctn.Resources.Requests).Quantities {
+			if v > dims[name] {
+				dims[name] = v
+			}
+		}
+	}
+	dims[ResourcePods] = 1
+	return FromQuantities(dims)
+}
@@ -1,21 +1,48 @@
 package types
 
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
 type Pod struct {
 	Name            string
 	Uid             string
 	NodeName        string
 	RequestMilliCpu int64
 	RequestMemory   int64
+	Requests        Resource
+	SchedulerName   string
+	Constraints     NodeConstraints
+	// Group names the PodGroup the pod belongs to, MinMember is the
+	// group's size. In a member's queue a pod with Group set and an empty
+	// Name stands for the whole group.
+	Group     string
+	MinMember int
+}
+
+// PodGroup is a gang of pods, e.g. of a distributed training job, that is
+// placed together or not at all.
+type PodGroup struct {
+	Name      string
+	Uid       string
+	MinMember int
+	Pods      []Pod
 }
 
+// Resource is a vector of named resource quantities. MilliCpu and Memory
+// (in MiB) mirror the cpu and memory dimensions for members that do not
+// know Quantities; use the methods in resource.go rather than the fields.
 type Resource struct {
-	MilliCpu int64
-	Memory   int64
+	MilliCpu   int64
+	Memory     int64
+	Quantities map[string]int64
 }
 
 type Node struct {
 	Name string
 	Resource
+	Labels map[string]string
+	Taints []v1.Taint
 }
 
 type User struct {